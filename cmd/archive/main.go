@@ -0,0 +1,61 @@
+// Command archive dumps records of the trades archive written by the Collector as JSON Lines.
+//
+//	go run ./cmd/archive -dir /archive -market Binance:BTC-USDT -from 2024-06-01T12:00:00Z -to 2024-06-01T12:05:00Z
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/archive"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	dir := flag.String("dir", utils.Getenv("ARCHIVE_DIR", ""), "archive directory")
+	market := flag.String("market", "", "market such as Binance:BTC-USDT, or BTC-USDT for all exchanges. Empty for all markets.")
+	recordType := flag.String("type", "", "record type: trade or tradesblock. Empty for both.")
+	fromFlag := flag.String("from", "", "start of the time range in RFC3339. Empty for no lower bound.")
+	toFlag := flag.String("to", "", "end of the time range in RFC3339. Empty for now.")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("archive directory must be given by -dir or ARCHIVE_DIR.")
+	}
+	if *recordType != "" && *recordType != archive.RecordTypeTrade && *recordType != archive.RecordTypeTradesBlock {
+		log.Fatalf("unknown record type %s.", *recordType)
+	}
+	from, err := parseTime(*fromFlag, time.Time{})
+	if err != nil {
+		log.Fatalf("parse -from: %v.", err)
+	}
+	to, err := parseTime(*toFlag, time.Now())
+	if err != nil {
+		log.Fatalf("parse -to: %v.", err)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	encoder := json.NewEncoder(out)
+	var count int
+	err = archive.Query(*dir, *recordType, *market, from, to, func(record archive.Record) error {
+		count++
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		out.Flush()
+		log.Fatalf("query archive: %v.", err)
+	}
+	log.Infof("%v records.", count)
+}
+
+func parseTime(value string, defaultTime time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultTime, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
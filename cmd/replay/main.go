@@ -0,0 +1,133 @@
+// Command replay recomputes the filter values a feeder would have published from recorded trades.
+// Trades are read from the archive written by the Collector and fed through the Collector and the
+// Processor on a simulated clock. Filter and metafilter settings are taken from the environment as in
+// the feeder, so candidate configurations can be compared on the same data:
+//
+//	DEX_MIN_LIQUIDITY_USD=50000 go run ./cmd/replay -input /archive -from 2024-06-01T12:00:00Z -to 2024-06-01T13:00:00Z -quotations quotations.json
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/archive"
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/replay"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// output is a line of the replay output. Key and Value are the oracle key and value that would have been published.
+type output struct {
+	Time   time.Time `json:"time"`
+	Key    string    `json:"key"`
+	Value  float64   `json:"value"`
+	Filter string    `json:"filter"`
+}
+
+func main() {
+	input := flag.String("input", utils.Getenv("ARCHIVE_DIR", ""), "archive directory or a single segment file")
+	market := flag.String("market", "", "only replay trades of this market, such as Binance:BTC-USDT or BTC-USDT. Empty for all markets.")
+	fromFlag := flag.String("from", "", "start of the time range in RFC3339. Empty for no lower bound.")
+	toFlag := flag.String("to", "", "end of the time range in RFC3339. Empty for now.")
+	frequencySeconds := flag.Int("frequency", 0, "trigger period in seconds. Defaults to FREQUENCY_SECONDS resp. 20.")
+	quotationsFile := flag.String("quotations", "", "JSON file mapping <Blockchain>-<Address> of base assets onto USD prices. Without it, base assets are priced by DIA's API at replay time, which is not reproducible.")
+	outputFile := flag.String("output", "", "output file. Empty for stdout.")
+	flag.Parse()
+
+	if *input == "" {
+		log.Fatal("input must be given by -input or ARCHIVE_DIR.")
+	}
+	from, err := parseTime(*fromFlag, time.Time{})
+	if err != nil {
+		log.Fatalf("parse -from: %v.", err)
+	}
+	to, err := parseTime(*toFlag, time.Now())
+	if err != nil {
+		log.Fatalf("parse -to: %v.", err)
+	}
+	if *frequencySeconds == 0 {
+		*frequencySeconds, err = strconv.Atoi(utils.Getenv("FREQUENCY_SECONDS", "20"))
+		if err != nil {
+			log.Fatalf("parse FREQUENCY_SECONDS: %v.", err)
+		}
+	}
+	if *frequencySeconds <= 0 {
+		log.Fatal("frequency must be positive.")
+	}
+
+	if *quotationsFile != "" {
+		content, err := os.ReadFile(*quotationsFile)
+		if err != nil {
+			log.Fatalf("read quotations: %v.", err)
+		}
+		quotations := make(map[string]float64)
+		if err := json.Unmarshal(content, &quotations); err != nil {
+			log.Fatalf("parse quotations: %v.", err)
+		}
+		utils.SetFixedAssetQuotations(quotations)
+	} else {
+		log.Warn("No -quotations given. Base assets other than USD are priced at replay time.")
+	}
+
+	trades, err := readTrades(*input, *market, from, to)
+	if err != nil {
+		log.Fatalf("read trades: %v.", err)
+	}
+	log.Infof("Replay %v trades.", len(trades))
+
+	out := os.Stdout
+	if *outputFile != "" {
+		out, err = os.Create(*outputFile)
+		if err != nil {
+			log.Fatalf("create output: %v.", err)
+		}
+		defer out.Close()
+	}
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+	encoder := json.NewEncoder(writer)
+
+	err = replay.Run(trades, time.Duration(*frequencySeconds)*time.Second, func(result replay.Result) error {
+		for _, fp := range result.FilterPoints {
+			err := encoder.Encode(output{Time: result.Time, Key: fp.Feed().Key(), Value: fp.Value, Filter: fp.Name})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writer.Flush()
+		log.Fatalf("replay: %v.", err)
+	}
+}
+
+// readTrades returns the trades of @market in [@from,@to] from the archive directory or segment file @input.
+func readTrades(input string, market string, from time.Time, to time.Time) ([]models.Trade, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	query := archive.QueryFile
+	if info.IsDir() {
+		query = archive.Query
+	}
+	var trades []models.Trade
+	err = query(input, archive.RecordTypeTrade, market, from, to, func(record archive.Record) error {
+		trades = append(trades, *record.Trade)
+		return nil
+	})
+	return trades, err
+}
+
+func parseTime(value string, defaultTime time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultTime, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"math/big"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	models "github.com/diadata-org/decentral-feeder/pkg/models"
@@ -17,6 +20,7 @@ import (
 	utils "github.com/diadata-org/decentral-feeder/pkg/utils"
 	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/prometheus/client_golang/prometheus"
@@ -32,6 +36,8 @@ const (
 	PAIR_TICKER_SEPARATOR = "-"
 	// Separator for a pair on a given exchange, i.e. Binance:BTC-USDT.
 	EXCHANGE_PAIR_SEPARATOR = ":"
+	// Separator for the pool addresses of a price conversion route, i.e. 0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc|0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852.
+	ROUTE_SEPARATOR = "|"
 )
 
 var (
@@ -45,8 +51,10 @@ var (
 	exchangePairsEnv = utils.Getenv("EXCHANGEPAIRS", "Crypto.com:BTC-USDT,Crypto.com:BTC-USD")
 	// Comma separated list of pools.
 	// The binary digit in the third position controls the order of the trades in the pool:
-	// TO DO: For 0 the original order is taken into consideration, while for 1 the order of all trades in the pool is reversed.
-	// Format should be as follows: UniswapV2:0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852:0,UniswapV2:0xc5be99A02C6857f9Eac67BbCE58DF5572498F40c:0
+	// For 0 the original order is taken into consideration, i.e. token0 is priced in units of token1, while for 1 the order of all trades in the pool is reversed.
+	// The optional fourth position is a list of pool addresses separated by ROUTE_SEPARATOR along which prices are converted,
+	// for instance from WETH into USDC.
+	// Format should be as follows: UniswapV2:0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852:0,UniswapV2:0xc5be99A02C6857f9Eac67BbCE58DF5572498F40c:0:0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc
 	poolsEnv = utils.Getenv("POOLS", "")
 
 	exchangePairs []models.ExchangePair
@@ -96,10 +104,24 @@ func init() {
 		// Extract pools from env var.
 		if poolsEnv != "" {
 			for _, p := range strings.Split(poolsEnv, ENV_SEPARATOR) {
+				poolInfo := strings.Split(strings.TrimSpace(p), EXCHANGE_PAIR_SEPARATOR)
+				if len(poolInfo) < 2 {
+					log.Fatalf("Invalid pool %s.", p)
+				}
 				var pool models.Pool
-				pool.Exchange = scrapers.Exchanges[strings.Split(p, EXCHANGE_PAIR_SEPARATOR)[0]]
-				pool.Address = strings.Split(p, EXCHANGE_PAIR_SEPARATOR)[1]
+				pool.Exchange = scrapers.Exchanges[poolInfo[0]]
+				pool.Address = poolInfo[1]
 				pool.Blockchain = models.Blockchain{Name: pool.Exchange.Blockchain}
+				if len(poolInfo) > 2 && poolInfo[2] != "" {
+					order, err := strconv.ParseUint(poolInfo[2], 10, 8)
+					if err != nil || order > 1 {
+						log.Fatalf("Invalid order %s for pool %s.", poolInfo[2], pool.Address)
+					}
+					pool.Order = uint8(order)
+				}
+				if len(poolInfo) > 3 && poolInfo[3] != "" {
+					pool.Route = strings.Split(poolInfo[3], ROUTE_SEPARATOR)
+				}
 				pools = append(pools, pool)
 			}
 		}
@@ -132,6 +154,10 @@ func init() {
 
 func main() {
 
+	// The root context is cancelled on SIGINT/SIGTERM, which shuts down the whole pipeline.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// get hostname of the container so that we can display it in monitoring dashboards
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -142,12 +168,16 @@ func main() {
 
 	reg := prometheus.NewRegistry()
 	m := NewMetrics(reg, pushgatewayURL, "df_"+hostname)
+	reg.MustRegister(scrapers.Metrics()...)
+	reg.MustRegister(onchain.Metrics()...)
 
 	// Record start time for uptime calculation
 	startTime := time.Now()
 
 	// Update metrics periodically
 	go func() {
+		ticker := time.NewTicker(10 * time.Second) // update metrics every 10 seconds
+		defer ticker.Stop()
 		for {
 			uptime := time.Since(startTime).Hours()
 			m.uptime.Set(uptime)
@@ -165,15 +195,22 @@ func main() {
 			}
 
 			// Push metrics to the Pushgateway
-			if err := push.New(m.pushGatewayURL, m.jobName).
+			pusher := push.New(m.pushGatewayURL, m.jobName).
 				Collector(m.uptime).
 				Collector(m.cpuUsage).
-				Collector(m.memoryUsage).
-				Push(); err != nil {
+				Collector(m.memoryUsage)
+			for _, collector := range append(scrapers.Metrics(), onchain.Metrics()...) {
+				pusher = pusher.Collector(collector)
+			}
+			if err := pusher.Push(); err != nil {
 				log.Errorf("Could not push metrics to Pushgateway: %v", err)
 			}
 
-			time.Sleep(10 * time.Second) // update metrics every 10 seconds
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
@@ -181,21 +218,29 @@ func main() {
 	tradesblockChannel := make(chan map[string]models.TradesBlock)
 	filtersChannel := make(chan []models.FilterPointExtended)
 	triggerChannel := make(chan time.Time)
-	failoverChannel := make(chan string)
 
 	// Feeder mechanics
-	privateKeyHex := utils.Getenv("PRIVATE_KEY", "")
 	deployedContract := utils.Getenv("DEPLOYED_CONTRACT", "")
+	// In a dry run, oracle updates are built and signed, but not sent, see onchain.OracleUpdateExecutor.
+	dryRun := utils.Getenv("DRY_RUN", "false") == "true"
 	blockchainNode := utils.Getenv("BLOCKCHAIN_NODE", "https://testnet-rpc.diadata.org")
-	backupNode := utils.Getenv("BACKUP_NODE", "https://testnet-rpc.diadata.org")
+	// BACKUP_NODE is a comma-separated list of nodes in order of priority.
+	backupNodes := utils.Getenv("BACKUP_NODE", "https://testnet-rpc.diadata.org")
 
 	conn, err := ethclient.Dial(blockchainNode)
 	if err != nil {
 		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
 	}
-	connBackup, err := ethclient.Dial(backupNode)
-	if err != nil {
-		log.Fatalf("Failed to connect to the backup Ethereum client: %v", err)
+	var connBackups []*ethclient.Client
+	for _, backupNode := range strings.Split(backupNodes, ",") {
+		if strings.TrimSpace(backupNode) == "" {
+			continue
+		}
+		connBackup, err := ethclient.Dial(strings.TrimSpace(backupNode))
+		if err != nil {
+			log.Fatalf("Failed to connect to the backup Ethereum client: %v", err)
+		}
+		connBackups = append(connBackups, connBackup)
 	}
 	chainId, err := strconv.ParseInt(utils.Getenv("CHAIN_ID", "10640"), 10, 64)
 	if err != nil {
@@ -208,37 +253,107 @@ func main() {
 		log.Fatalf("Failed to parse frequencySeconds: %v", err)
 	}
 
-	privateKeyHex = strings.TrimPrefix(privateKeyHex, "0x")
+	// The transactor signs with the signer selected by SIGNER, see onchain.NewTransactorFromEnv.
+	auth, err := onchain.NewTransactorFromEnv(big.NewInt(chainId))
+	if err != nil && dryRun {
+		// A dry run does not need a funded key, so sign with a throwaway key.
+		log.Warnf("Failed to create authorized transactor: %v. Dry run with an ephemeral key.", err)
+		privateKey, keyErr := crypto.GenerateKey()
+		if keyErr != nil {
+			log.Fatalf("Failed to generate ephemeral key: %v", keyErr)
+		}
+		auth, err = bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(chainId))
+	}
+	if err != nil {
+		log.Fatalf("Failed to create authorized transactor: %v", err)
+	}
+	log.Infof("Sign transactions of %s.", auth.From.Hex())
+
+	if dryRun && deployedContract == "" {
+		// Never deploy in a dry run.
+		deployedContract = common.Address{}.Hex()
+		log.Warnf("DEPLOYED_CONTRACT is not set. Dry run against %s.", deployedContract)
+	}
 
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	var (
+		contract        *diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService
+		contractBackups []*diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService
+	)
+	err = onchain.DeployOrBindContract(deployedContract, conn, connBackups, auth, &contract, &contractBackups)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		log.Fatalf("Failed to Deploy or Bind primary and backup contract: %v", err)
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(chainId))
+	// Transactions are sent through the primary node and fail over to the backup nodes.
+	nodes := []onchain.Node{{Name: "primary", Client: conn, Contract: contract}}
+	for i := range connBackups {
+		nodes = append(nodes, onchain.Node{Name: "backup" + strconv.Itoa(i+1), Client: connBackups[i], Contract: contractBackups[i]})
+	}
+	nodePool, err := onchain.NewNodePool(nodes)
 	if err != nil {
-		log.Fatalf("Failed to create authorized transactor: %v", err)
+		log.Fatalf("Failed to create node pool: %v", err)
 	}
+	go nodePool.Run(ctx)
 
-	var contract, contractBackup *diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService
-	err = onchain.DeployOrBindContract(deployedContract, conn, connBackup, auth, &contract, &contractBackup)
+	gasStrategy, err := onchain.GasStrategyFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to Deploy or Bind primary and backup contract: %v", err)
+		log.Fatalf("Failed to select gas strategy: %v", err)
 	}
 
 	// Use a ticker for triggering the processing.
 	// This is for testing purposes for now. Could also be request based or other trigger types.
 	triggerTick := time.NewTicker(time.Duration(frequencySeconds) * time.Second)
 	go func() {
-		for tick := range triggerTick.C {
-			// log.Info("Trigger - tick at: ", tick)
-			triggerChannel <- tick
+		defer triggerTick.Stop()
+		for {
+			select {
+			case tick := <-triggerTick.C:
+				select {
+				case triggerChannel <- tick:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
 	// Run Processor and subsequent routines.
-	go processor.Processor(exchangePairs, pools, tradesblockChannel, filtersChannel, triggerChannel, failoverChannel, &wg)
+	go processor.Processor(ctx, exchangePairs, pools, tradesblockChannel, filtersChannel, triggerChannel, &wg)
+
+	// The published values of the assets of CEX pairs are loaded at startup. The assets of DEX pools are only known
+	// once their scrapers resolved the pools, so their published values are read when their first value arrives.
+	var feeds []models.Feed
+	assets := make(map[string]struct{})
+	for _, ep := range exchangePairs {
+		asset := ep.UnderlyingPair.QuoteToken
+		if _, ok := assets[asset.Symbol]; ok || asset.Symbol == "" {
+			continue
+		}
+		assets[asset.Symbol] = struct{}{}
+		feeds = append(feeds, models.Feed{Asset: asset})
+	}
 
 	// Outlook/Alternative: The triggerChannel can also be filled by the oracle updater by any other mechanism.
-	onchain.OracleUpdateExecutor(auth, contract, conn, chainId, filtersChannel)
+	// OracleUpdateExecutor returns once the pipeline is drained after shutdown.
+	onchain.OracleUpdateExecutor(ctx, auth, nodePool, gasStrategy, dryRun, feeds, filtersChannel)
+
+	// The scrapers were stopped with @ctx. Wait for them to return, at most SHUTDOWN_TIMEOUT_SECONDS.
+	shutdownTimeoutSeconds, err := strconv.Atoi(utils.Getenv("SHUTDOWN_TIMEOUT_SECONDS", "30"))
+	if err != nil {
+		log.Errorf("Failed to parse SHUTDOWN_TIMEOUT_SECONDS: %v", err)
+		shutdownTimeoutSeconds = 30
+	}
+	scrapersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(scrapersDone)
+	}()
+	select {
+	case <-scrapersDone:
+	case <-time.After(time.Duration(shutdownTimeoutSeconds) * time.Second):
+		log.Warn("Scrapers did not stop before shutdown timeout.")
+	}
+	log.Info("Feeder stopped.")
 }
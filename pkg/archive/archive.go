@@ -0,0 +1,292 @@
+// Package archive is an append-only store of the trades received by the Collector and the tradesblocks
+// handed to the Processor. Records are written as JSON Lines to segment files in a local directory.
+// Segments are rotated by size and age and removed after the retention period.
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+)
+
+const (
+	RecordTypeTrade       = "trade"
+	RecordTypeTradesBlock = "tradesblock"
+
+	segmentPrefix = "segment-"
+	segmentSuffix = ".jsonl"
+)
+
+// Record is a line of a segment file.
+type Record struct {
+	Type string `json:"type"`
+	// Market is the exchange and the symbols of the pair, such as Binance:BTC-USDT.
+	Market string `json:"market"`
+	// Time is the time of a trade resp. the end time of a tradesblock.
+	Time time.Time `json:"time"`
+	// Received is the time the record was written.
+	Received time.Time `json:"received"`
+	// Identifier is the exchangepair identifier of a tradesblock.
+	Identifier  string              `json:"identifier,omitempty"`
+	Trade       *models.Trade       `json:"trade,omitempty"`
+	TradesBlock *models.TradesBlock `json:"tradesblock,omitempty"`
+}
+
+// Config determines location, rotation and retention of the archive.
+type Config struct {
+	Dir string
+	// A new segment is started once the current one exceeds SegmentBytes or SegmentDuration.
+	SegmentBytes    int64
+	SegmentDuration time.Duration
+	// Segments older than Retention are removed. A Retention of 0 keeps all segments.
+	Retention time.Duration
+}
+
+// ConfigFromEnv returns the archive config given by the ARCHIVE_* environment variables.
+// The archive is disabled if ARCHIVE_DIR is empty.
+func ConfigFromEnv() (config Config, err error) {
+	config.Dir = utils.Getenv("ARCHIVE_DIR", "")
+	config.SegmentBytes, err = strconv.ParseInt(utils.Getenv("ARCHIVE_SEGMENT_BYTES", "67108864"), 10, 64)
+	if err != nil {
+		return config, fmt.Errorf("parse ARCHIVE_SEGMENT_BYTES: %w", err)
+	}
+	segmentSeconds, err := strconv.Atoi(utils.Getenv("ARCHIVE_SEGMENT_SECONDS", "3600"))
+	if err != nil {
+		return config, fmt.Errorf("parse ARCHIVE_SEGMENT_SECONDS: %w", err)
+	}
+	config.SegmentDuration = time.Duration(segmentSeconds) * time.Second
+	retentionHours, err := strconv.Atoi(utils.Getenv("ARCHIVE_RETENTION_HOURS", "168"))
+	if err != nil {
+		return config, fmt.Errorf("parse ARCHIVE_RETENTION_HOURS: %w", err)
+	}
+	config.Retention = time.Duration(retentionHours) * time.Hour
+	return config, nil
+}
+
+// Archive writes records to the current segment.
+type Archive struct {
+	mu           sync.Mutex
+	config       Config
+	file         *os.File
+	writer       *bufio.Writer
+	segmentStart time.Time
+	segmentSize  int64
+	now          func() time.Time
+}
+
+// Open creates the archive directory if needed, removes expired segments and starts a new segment.
+func Open(config Config) (*Archive, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	archive := &Archive{config: config, now: time.Now}
+	if err := archive.rotate(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// Market returns the market of @trade, such as Binance:BTC-USDT.
+func Market(trade models.Trade) string {
+	return trade.Exchange.Name + ":" + trade.QuoteToken.Symbol + "-" + trade.BaseToken.Symbol
+}
+
+// WriteTrade appends @trade to the archive.
+func (archive *Archive) WriteTrade(trade models.Trade) error {
+	return archive.write(Record{
+		Type:   RecordTypeTrade,
+		Market: Market(trade),
+		Time:   trade.Time,
+		Trade:  &trade,
+	})
+}
+
+// WriteTradesBlock appends @tradesblock with exchangepair identifier @identifier to the archive.
+func (archive *Archive) WriteTradesBlock(identifier string, tradesblock models.TradesBlock) error {
+	market := ""
+	if len(tradesblock.Trades) > 0 {
+		market = Market(tradesblock.Trades[0])
+	}
+	return archive.write(Record{
+		Type:        RecordTypeTradesBlock,
+		Market:      market,
+		Time:        tradesblock.EndTime,
+		Identifier:  identifier,
+		TradesBlock: &tradesblock,
+	})
+}
+
+func (archive *Archive) write(record Record) error {
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	record.Received = archive.now()
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	full := archive.segmentSize > 0 && archive.segmentSize+int64(len(line)) > archive.config.SegmentBytes
+	expired := archive.config.SegmentDuration > 0 && record.Received.Sub(archive.segmentStart) >= archive.config.SegmentDuration
+	if full || expired {
+		if err := archive.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := archive.writer.Write(line)
+	archive.segmentSize += int64(n)
+	return err
+}
+
+// Flush writes buffered records to the current segment.
+func (archive *Archive) Flush() error {
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	return archive.writer.Flush()
+}
+
+// Close flushes and closes the current segment.
+func (archive *Archive) Close() error {
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	if err := archive.writer.Flush(); err != nil {
+		return err
+	}
+	return archive.file.Close()
+}
+
+// rotate closes the current segment, removes expired segments and starts a new segment.
+// The caller must hold the lock.
+func (archive *Archive) rotate() error {
+	if archive.file != nil {
+		if err := archive.writer.Flush(); err != nil {
+			return err
+		}
+		if err := archive.file.Close(); err != nil {
+			return err
+		}
+	}
+	if err := archive.removeExpiredSegments(); err != nil {
+		return err
+	}
+
+	archive.segmentStart = archive.now()
+	name := filepath.Join(archive.config.Dir, fmt.Sprintf("%s%020d%s", segmentPrefix, archive.segmentStart.UnixNano(), segmentSuffix))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	archive.file = file
+	archive.writer = bufio.NewWriter(file)
+	archive.segmentSize = 0
+	return nil
+}
+
+func (archive *Archive) removeExpiredSegments() error {
+	if archive.config.Retention <= 0 {
+		return nil
+	}
+	segments, err := Segments(archive.config.Dir)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		info, err := os.Stat(segment)
+		if err != nil {
+			return err
+		}
+		if archive.now().Sub(info.ModTime()) > archive.config.Retention {
+			if err := os.Remove(segment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Segments returns the paths of all segments in @dir from oldest to newest.
+func Segments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), segmentPrefix) && strings.HasSuffix(entry.Name(), segmentSuffix) {
+			segments = append(segments, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// Segment names contain the zero-padded start time, so lexical order is chronological.
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// Query calls @fn for each record in @dir of type @recordType in @market with time in [@from,@to].
+// Empty @recordType and @market match all records. A @market without exchange such as BTC-USDT
+// matches the pair on all exchanges.
+func Query(dir string, recordType string, market string, from time.Time, to time.Time, fn func(Record) error) error {
+	segments, err := Segments(dir)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if err := QueryFile(segment, recordType, market, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryFile calls @fn for each matching record in the segment or JSON Lines file @segment. See Query.
+func QueryFile(segment string, recordType string, market string, from time.Time, to time.Time, fn func(Record) error) error {
+	file, err := os.Open(segment)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// Tradesblocks of liquid markets make for long lines.
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// The last line of a segment may be incomplete after a crash.
+			continue
+		}
+		if recordType != "" && record.Type != recordType {
+			continue
+		}
+		if !matchMarket(record.Market, market) {
+			continue
+		}
+		if record.Time.Before(from) || record.Time.After(to) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func matchMarket(recordMarket string, market string) bool {
+	if market == "" || recordMarket == market {
+		return true
+	}
+	if !strings.Contains(market, ":") {
+		return strings.HasSuffix(recordMarket, ":"+market)
+	}
+	return false
+}
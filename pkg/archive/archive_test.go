@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+)
+
+func makeTrade(exchange string, symbol string, price float64, t time.Time) models.Trade {
+	return models.Trade{
+		QuoteToken: models.Asset{Symbol: symbol},
+		BaseToken:  models.Asset{Symbol: "USDT"},
+		Exchange:   models.Exchange{Name: exchange},
+		Price:      price,
+		Time:       t,
+	}
+}
+
+func TestArchiveRotationAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := t0
+
+	// The clock of the archive follows the trades, such that the second minute starts a new segment.
+	archive := &Archive{config: Config{Dir: dir, SegmentBytes: 1 << 20, SegmentDuration: time.Minute}, now: func() time.Time { return now }}
+	if err := archive.rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	trades := []models.Trade{
+		makeTrade("Binance", "BTC", 1, t0),
+		makeTrade("Kraken", "BTC", 2, t0.Add(30*time.Second)),
+		makeTrade("Binance", "ETH", 3, t0.Add(90*time.Second)),
+	}
+	for _, trade := range trades {
+		now = trade.Time
+		if err := archive.WriteTrade(trade); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tradesblock := models.TradesBlock{Trades: trades[:1], StartTime: t0, EndTime: t0.Add(20 * time.Second)}
+	if err := archive.WriteTradesBlock("Binance-BTC", tradesblock); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := Segments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("got %v segments, want 2", len(segments))
+	}
+
+	cases := []struct {
+		recordType string
+		market     string
+		to         time.Time
+		prices     []float64
+	}{
+		{RecordTypeTrade, "", t0.Add(time.Hour), []float64{1, 2, 3}},
+		{RecordTypeTrade, "BTC-USDT", t0.Add(time.Hour), []float64{1, 2}},
+		{RecordTypeTrade, "Binance:BTC-USDT", t0.Add(time.Hour), []float64{1}},
+		{RecordTypeTrade, "", t0.Add(time.Minute), []float64{1, 2}},
+		{RecordTypeTradesBlock, "Binance:BTC-USDT", t0.Add(time.Hour), []float64{1}},
+	}
+	for _, c := range cases {
+		var prices []float64
+		err := Query(dir, c.recordType, c.market, t0, c.to, func(record Record) error {
+			if record.Trade != nil {
+				prices = append(prices, record.Trade.Price)
+			}
+			if record.TradesBlock != nil {
+				prices = append(prices, record.TradesBlock.Trades[0].Price)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prices) != len(c.prices) {
+			t.Errorf("%s %s: got %v, want %v", c.recordType, c.market, prices, c.prices)
+			continue
+		}
+		for i := range prices {
+			if prices[i] != c.prices[i] {
+				t.Errorf("%s %s: got %v, want %v", c.recordType, c.market, prices, c.prices)
+			}
+		}
+	}
+}
+
+func TestArchiveRetention(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := Open(Config{Dir: dir, SegmentBytes: 1 << 20, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.WriteTrade(makeTrade("Binance", "BTC", 1, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	segments, err := Segments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("got segments %v, err %v", segments, err)
+	}
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(segments[0], expired, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err = Open(Config{Dir: dir, SegmentBytes: 1 << 20, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if _, err := os.Stat(segments[0]); !os.IsNotExist(err) {
+		t.Errorf("expired segment %s was not removed", segments[0])
+	}
+}
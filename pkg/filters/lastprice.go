@@ -1,7 +1,6 @@
 package filters
 
 import (
-	"encoding/json"
 	"time"
 
 	models "github.com/diadata-org/decentral-feeder/pkg/models"
@@ -32,26 +31,13 @@ func LastPrice(trades []models.Trade, USDPrice bool) (lastPrice float64, timesta
 
 	// Fetch USD price of basetoken from DIA API.
 	if USDPrice {
-		type assetQuotation struct {
-			Price  float64 `json:"Price"`
-			Volume float64 `json:"VolumeYesterdayUSD"`
-		}
-		var (
-			response []byte
-			aq       assetQuotation
-		)
-
-		baseString := "https://api.diadata.org/v1/assetQuotation/" + lastTrade.BaseToken.Blockchain + "/" + lastTrade.BaseToken.Address
-		response, _, err = utils.GetRequest(baseString)
-		if err != nil {
-			log.Debugf("GetRequest for %s on %s", lastTrade.BaseToken.Address, lastTrade.BaseToken.Blockchain)
-			return
-		}
-		err = json.Unmarshal(response, &aq)
+		var basePrice float64
+		basePrice, err = utils.GetAssetQuotation(lastTrade.BaseToken.Blockchain, lastTrade.BaseToken.Address)
 		if err != nil {
+			log.Debugf("GetAssetQuotation for %s on %s", lastTrade.BaseToken.Address, lastTrade.BaseToken.Blockchain)
 			return
 		}
-		lastPrice = aq.Price * lastTrade.Price
+		lastPrice = basePrice * lastTrade.Price
 
 	} else {
 		lastPrice = lastTrade.Price
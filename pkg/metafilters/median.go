@@ -9,15 +9,16 @@ const (
 	medianFilterName = "median"
 )
 
-// Median returns the median value for all filter points that share the same quote asset.
+// Median returns the median value for all filter points that share the same quote asset and swap size.
 func Median(filterPoints []models.FilterPointExtended) (medianizedFilterPoints []models.FilterPointExtended) {
-	filterAssetMap := models.GroupFilterByAsset(filterPoints)
+	filterFeedMap := models.GroupFilterByFeed(filterPoints)
 
-	for asset, filters := range filterAssetMap {
+	for feed, filters := range filterFeedMap {
 		filterValue := utils.Median(models.GetValuesFromFilterPoints(filters))
 		var fp models.FilterPointExtended
 		fp.Value = filterValue
-		fp.Pair.QuoteToken = asset
+		fp.Pair.QuoteToken = feed.Asset
+		fp.Notional = feed.Notional
 		fp.Name = medianFilterName
 		fp.Time = models.GetLatestTimestampFromFilterPoints(filters)
 		medianizedFilterPoints = append(medianizedFilterPoints, fp)
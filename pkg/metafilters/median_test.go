@@ -100,13 +100,44 @@ func TestMedian(t *testing.T) {
 				},
 			},
 		},
+
+		{
+			[]models.FilterPointExtended{
+				{
+					Pair:  models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value: 3143.3,
+				},
+				{
+					Pair:     models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value:    3101.4,
+					Notional: 100000,
+				},
+				{
+					Pair:  models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value: 3179.78,
+				},
+			},
+			[]models.FilterPointExtended{
+				{
+					Pair:  models.Pair{QuoteToken: ETH},
+					Value: 3161.54,
+					Name:  "median",
+				},
+				{
+					Pair:     models.Pair{QuoteToken: ETH},
+					Value:    3101.4,
+					Name:     "median",
+					Notional: 100000,
+				},
+			},
+		},
 	}
 
 	for i, c := range cases {
 		medianizedFilterPoints := Median(c.filterPoints)
 
 		// Make maps from slices in order to deep compare.
-		if !reflect.DeepEqual(models.GroupFilterByAsset(medianizedFilterPoints), models.GroupFilterByAsset(c.medianizedFilterPoints)) {
+		if !reflect.DeepEqual(models.GroupFilterByFeed(medianizedFilterPoints), models.GroupFilterByFeed(c.medianizedFilterPoints)) {
 			t.Errorf("Median was incorrect, got: %v, expected: %v for set:%d", medianizedFilterPoints, c.medianizedFilterPoints, i)
 		}
 
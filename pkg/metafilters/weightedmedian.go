@@ -0,0 +1,44 @@
+package metafilters
+
+import (
+	"math"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	utils "github.com/diadata-org/decentral-feeder/pkg/utils"
+)
+
+const (
+	liquidityWeightedMedianFilterName = "liquidityweightedmedian"
+)
+
+// LiquidityWeightedMedian returns the weighted median value for all filter points that share the same quote asset and swap size.
+// Filter points with liquidity information, i.e. filter points from DEX pools, are weighted by their liquidity
+// relative to @referenceLiquidityUSD, capped at 1. All other filter points have weight 1.
+// Hence, a pool with little liquidity can hardly move the resulting value.
+func LiquidityWeightedMedian(filterPoints []models.FilterPointExtended, referenceLiquidityUSD float64) (medianizedFilterPoints []models.FilterPointExtended) {
+	filterFeedMap := models.GroupFilterByFeed(filterPoints)
+
+	for feed, filters := range filterFeedMap {
+		var weights []float64
+		for _, fp := range filters {
+			weights = append(weights, liquidityWeight(fp, referenceLiquidityUSD))
+		}
+		filterValue := utils.WeightedMedian(models.GetValuesFromFilterPoints(filters), weights)
+		var fp models.FilterPointExtended
+		fp.Value = filterValue
+		fp.Pair.QuoteToken = feed.Asset
+		fp.Notional = feed.Notional
+		fp.Name = liquidityWeightedMedianFilterName
+		fp.Time = models.GetLatestTimestampFromFilterPoints(filters)
+		medianizedFilterPoints = append(medianizedFilterPoints, fp)
+	}
+
+	return
+}
+
+func liquidityWeight(fp models.FilterPointExtended, referenceLiquidityUSD float64) float64 {
+	if fp.LiquidityUSD <= 0 || referenceLiquidityUSD <= 0 {
+		return 1
+	}
+	return math.Min(fp.LiquidityUSD/referenceLiquidityUSD, 1)
+}
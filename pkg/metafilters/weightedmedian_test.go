@@ -0,0 +1,89 @@
+package metafilters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/diadata-org/decentral-feeder/pkg/models"
+)
+
+func TestLiquidityWeightedMedian(t *testing.T) {
+	cases := []struct {
+		filterPoints           []models.FilterPointExtended
+		referenceLiquidityUSD  float64
+		medianizedFilterPoints []models.FilterPointExtended
+	}{
+		{
+			[]models.FilterPointExtended{
+				{
+					Pair:  models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value: 3388.34,
+				},
+				{
+					Pair:         models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value:        1200.5,
+					LiquidityUSD: 4000,
+				},
+				{
+					Pair:         models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value:        1100.2,
+					LiquidityUSD: 3000,
+				},
+				{
+					Pair:  models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value: 3381.11,
+				},
+			},
+			1e6,
+			[]models.FilterPointExtended{
+				{
+					Pair:  models.Pair{QuoteToken: ETH},
+					Value: 3381.11,
+					Name:  "liquidityweightedmedian",
+				},
+			},
+		},
+		{
+			[]models.FilterPointExtended{
+				{
+					Pair:  models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value: 3143.3,
+				},
+				{
+					Pair:         models.Pair{QuoteToken: ETH, BaseToken: USDC},
+					Value:        3281.11,
+					LiquidityUSD: 2e6,
+				},
+				{
+					Pair:         models.Pair{QuoteToken: BTC, BaseToken: USDC},
+					Value:        62344.9,
+					LiquidityUSD: 1e5,
+				},
+			},
+			1e6,
+			[]models.FilterPointExtended{
+				{
+					Pair:  models.Pair{QuoteToken: ETH},
+					Value: 3212.205,
+					Name:  "liquidityweightedmedian",
+				},
+				{
+					Pair:  models.Pair{QuoteToken: BTC},
+					Value: 62344.9,
+					Name:  "liquidityweightedmedian",
+				},
+			},
+		},
+	}
+
+	for i, c := range cases {
+		medianizedFilterPoints := LiquidityWeightedMedian(c.filterPoints, c.referenceLiquidityUSD)
+
+		// Make maps from slices in order to deep compare.
+		if !reflect.DeepEqual(models.GroupFilterByAsset(medianizedFilterPoints), models.GroupFilterByAsset(c.medianizedFilterPoints)) {
+			t.Errorf("LiquidityWeightedMedian was incorrect, got: %v, expected: %v for set:%d", medianizedFilterPoints, c.medianizedFilterPoints, i)
+		}
+
+	}
+
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 // FilterPoint contains the resulting value of a filter applied to an asset.
 type FilterPoint struct {
@@ -18,6 +21,30 @@ type FilterPointExtended struct {
 	Name   string
 	Time   time.Time
 	Source string
+	// LiquidityUSD is the liquidity of the underlying DEX pool. Zero if not available, for instance for CEX sources.
+	LiquidityUSD float64
+	// Notional is the swap size of a size feed. Zero for spot feeds.
+	Notional float64
+}
+
+// Feed identifies the value published for an asset. Spot feeds have zero notional.
+type Feed struct {
+	Asset    Asset
+	Notional float64
+}
+
+// Key returns the oracle key of the feed, i.e. BTC/USD for spot and BTC/USD@100000 for a size feed.
+func (f Feed) Key() string {
+	key := f.Asset.Symbol + "/USD"
+	if f.Notional > 0 {
+		key += "@" + strconv.FormatFloat(f.Notional, 'f', -1, 64)
+	}
+	return key
+}
+
+// Feed returns the feed the filter point belongs to.
+func (fp *FilterPointExtended) Feed() Feed {
+	return Feed{Asset: fp.Pair.QuoteToken, Notional: fp.Notional}
 }
 
 // GroupFilterByAsset returns @fpMap which maps an asset on all extended filter points contained in @filterPoints.
@@ -29,6 +56,16 @@ func GroupFilterByAsset(filterPoints []FilterPointExtended) (fpMap map[Asset][]F
 	return
 }
 
+// GroupFilterByFeed returns @fpMap which maps a feed on all extended filter points contained in @filterPoints.
+// In contrast to GroupFilterByAsset, filter points of different swap sizes are kept apart.
+func GroupFilterByFeed(filterPoints []FilterPointExtended) (fpMap map[Feed][]FilterPointExtended) {
+	fpMap = make(map[Feed][]FilterPointExtended)
+	for _, fp := range filterPoints {
+		fpMap[fp.Feed()] = append(fpMap[fp.Feed()], fp)
+	}
+	return
+}
+
 // GetValuesFromFilterPoints returns a slice containing just the values from @filterPoints.
 func GetValuesFromFilterPoints(filterPoints []FilterPointExtended) (filterValues []float64) {
 	for _, fp := range filterPoints {
@@ -59,3 +96,16 @@ func RemoveOldFilters(filterPoints []FilterPointExtended, toleranceSeconds int64
 	}
 	return
 }
+
+// RemoveIlliquidFilters removes all filter points from @filterPoints with a known liquidity below @minLiquidityUSD.
+// Filter points without liquidity information, such as filter points from centralized exchanges, are kept.
+func RemoveIlliquidFilters(filterPoints []FilterPointExtended, minLiquidityUSD float64) (cleanedFilterPoints []FilterPointExtended, removedFilters int) {
+	for _, fp := range filterPoints {
+		if fp.LiquidityUSD > 0 && fp.LiquidityUSD < minLiquidityUSD {
+			removedFilters++
+			continue
+		}
+		cleanedFilterPoints = append(cleanedFilterPoints, fp)
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/diadata-org/decentral-feeder/pkg/utils"
@@ -14,12 +15,57 @@ type Pool struct {
 	Address      string        `json:"Address"`
 	Assetvolumes []AssetVolume `json:"Assetvolumes"`
 	Time         time.Time     `json:"Time"`
+	// Order determines which of the pool's assets is priced. For 0, the asset with index 0 is the quote token,
+	// i.e. the priced asset, and the asset with index 1 is the base token. For 1, the order is reversed.
+	Order uint8 `json:"Order"`
+	// Route is an optional list of pool addresses on the same exchange. Prices are converted along these pools
+	// from the pool's base token into the base token of the last pool, for instance TOKEN/WETH → WETH/USDC.
+	Route []string `json:"Route"`
 }
 
 type AssetVolume struct {
 	Asset  Asset   `json:"Asset"`
 	Volume float64 `json:"Volume"`
 	Index  uint8   `json:"Index"`
+	// VolumeUSD is the USD value of @Volume. Zero if no USD price is available for @Asset.
+	VolumeUSD float64 `json:"VolumeUSD"`
+}
+
+// LiquidityUSD returns the USD value of the pool's reserves.
+// Reserves without USD value are valued as the average of the remaining reserves. This is exact
+// for pools with equally weighted reserves such as UniswapV2 pools.
+func (p *Pool) LiquidityUSD() (liquidity float64) {
+	var pricedAssets int
+	for _, av := range p.Assetvolumes {
+		if av.VolumeUSD > 0 {
+			liquidity += av.VolumeUSD
+			pricedAssets++
+		}
+	}
+	if pricedAssets == 0 {
+		return
+	}
+	return liquidity * float64(len(p.Assetvolumes)) / float64(pricedAssets)
+}
+
+// SpotPrice returns the price of the asset with index @quoteIndex in units of the asset with index @baseIndex,
+// computed from the pool's reserves. This is the marginal price of a constant product pool such as UniswapV2.
+func (p *Pool) SpotPrice(quoteIndex uint8, baseIndex uint8) (price float64, err error) {
+	var quoteVolume, baseVolume float64
+	for _, av := range p.Assetvolumes {
+		switch av.Index {
+		case quoteIndex:
+			quoteVolume = av.Volume
+		case baseIndex:
+			baseVolume = av.Volume
+		}
+	}
+	if quoteVolume == 0 {
+		err = errors.New("no reserves for quote asset")
+		return
+	}
+	price = baseVolume / quoteVolume
+	return
 }
 
 func MakePoolMap(pools []Pool) map[string][]Pool {
@@ -11,8 +11,16 @@ type Trade struct {
 	Exchange       Exchange
 	PoolAddress    string
 	ForeignTradeID string
+	// BlockNumber is the number of the block containing the trade. Only set for on-chain trades.
+	BlockNumber uint64
 	// Depending on the connection to the processing layer we might not need it here.
 	EstimatedUSDPrice float64
+	// LiquidityUSD is the USD value of the pool's reserves at the time of the trade.
+	// It is only set for trades from DEX pools with tracked reserves.
+	LiquidityUSD float64
+	// Notional is the size of a simulated swap in units of the base token. Trades with a non-zero
+	// notional make up a separate size feed and are not aggregated with spot trades.
+	Notional float64
 }
 
 // Struct for decentralized scraper pools.
@@ -0,0 +1,144 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+)
+
+// gasLimitErrors are estimation errors of transactions that need more gas than a block or the RPC allows.
+var gasLimitErrors = []string{
+	"gas required exceeds",
+	"exceeds block gas limit",
+	"out of gas",
+	"gas limit reached",
+}
+
+func isGasLimitError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, gasLimitError := range gasLimitErrors {
+		if strings.Contains(message, gasLimitError) {
+			return true
+		}
+	}
+	return false
+}
+
+// batch is a part of an oracle update sent in one transaction.
+type batch struct {
+	keys   []string
+	values []*big.Int
+	gas    uint64
+}
+
+// batcher splits an oracle update into transactions that each use at most TX_GAS_LIMIT gas. Per round, that is
+// per trigger or retry, at most UPDATE_GAS_BUDGET gas is used. Keys that do not fit are deferred to the next round.
+// If TX_GAS_LIMIT or UPDATE_GAS_BUDGET are 0, they default to half of and to the gas limit of the latest block.
+type batcher struct {
+	// estimate returns the gas of a transaction writing @values of @keys at @timestamp.
+	estimate func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (uint64, error)
+	// blockGasLimit returns the gas limit of the latest block.
+	blockGasLimit  func(ctx context.Context) (uint64, error)
+	txGasLimit     uint64
+	roundGasBudget uint64
+}
+
+func newBatcher(nodes *NodePool, estimate func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (uint64, error)) (*batcher, error) {
+	txGasLimit, err := strconv.ParseUint(utils.Getenv("TX_GAS_LIMIT", "0"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse TX_GAS_LIMIT: %w", err)
+	}
+	roundGasBudget, err := strconv.ParseUint(utils.Getenv("UPDATE_GAS_BUDGET", "0"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse UPDATE_GAS_BUDGET: %w", err)
+	}
+	return &batcher{
+		estimate: estimate,
+		blockGasLimit: func(ctx context.Context) (uint64, error) {
+			ctx, cancel := context.WithTimeout(ctx, nodes.timeout)
+			defer cancel()
+			header, err := nodes.Active().Client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return 0, err
+			}
+			return header.GasLimit, nil
+		},
+		txGasLimit:     txGasLimit,
+		roundGasBudget: roundGasBudget,
+	}, nil
+}
+
+// limits returns the gas limit per transaction and the gas budget of the round.
+func (b *batcher) limits(ctx context.Context) (uint64, uint64, error) {
+	txGasLimit, roundGasBudget := b.txGasLimit, b.roundGasBudget
+	if txGasLimit == 0 || roundGasBudget == 0 {
+		blockGasLimit, err := b.blockGasLimit(ctx)
+		if err != nil {
+			return 0, 0, err
+		}
+		if txGasLimit == 0 {
+			txGasLimit = blockGasLimit / 2
+		}
+		if roundGasBudget == 0 {
+			roundGasBudget = blockGasLimit
+		}
+	}
+	if txGasLimit > roundGasBudget {
+		txGasLimit = roundGasBudget
+	}
+	return txGasLimit, roundGasBudget, nil
+}
+
+// split returns the batches of this round for @values of @keys at @timestamp. @keys are ordered by priority,
+// such that the keys with the highest priority are sent first and the keys that are deferred have the lowest.
+func (b *batcher) split(ctx context.Context, keys []string, values []*big.Int, timestamp int64) ([]batch, error) {
+	txGasLimit, roundGasBudget, err := b.limits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		batches []batch
+		total   uint64
+	)
+	for start := 0; start < len(keys); {
+		n := len(keys) - start
+		var gas uint64
+		for {
+			gas, err = b.estimate(ctx, keys[start:start+n], values[start:start+n], timestamp)
+			if err != nil {
+				if n > 1 && isGasLimitError(err) {
+					n /= 2
+					continue
+				}
+				return batches, err
+			}
+			if gas <= txGasLimit {
+				break
+			}
+			if n == 1 {
+				return batches, fmt.Errorf("update of %s needs %v gas, more than TX_GAS_LIMIT %v", keys[start], gas, txGasLimit)
+			}
+			// Gas grows about linearly with the number of keys.
+			shrunk := int(uint64(n) * txGasLimit / gas)
+			if shrunk >= n {
+				shrunk = n - 1
+			}
+			if shrunk < 1 {
+				shrunk = 1
+			}
+			n = shrunk
+		}
+		if total+gas > roundGasBudget {
+			break
+		}
+		total += gas
+		batches = append(batches, batch{keys: keys[start : start+n], values: values[start : start+n], gas: gas})
+		start += n
+	}
+	return batches, nil
+}
@@ -0,0 +1,89 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// estimateLinear charges 21000 gas per transaction and 30000 gas per key, and fails for more than 4 keys
+// like a node whose call gas limit is exceeded.
+func estimateLinear(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (uint64, error) {
+	if len(keys) > 4 {
+		return 0, errors.New("gas required exceeds allowance (150000)")
+	}
+	return 21000 + 30000*uint64(len(keys)), nil
+}
+
+func TestBatcherSplit(t *testing.T) {
+	b := &batcher{estimate: estimateLinear, txGasLimit: 100000, roundGasBudget: 200000}
+	keys := []string{"A", "B", "C", "D", "E"}
+	batches, err := b.split(context.Background(), keys, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5)}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two keys fit into a transaction and two transactions into the round, so E is deferred.
+	var got [][]string
+	for _, batch := range batches {
+		if batch.gas > b.txGasLimit {
+			t.Errorf("batch %v uses %v gas", batch.keys, batch.gas)
+		}
+		got = append(got, batch.keys)
+	}
+	if want := [][]string{{"A", "B"}, {"C", "D"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got batches %v, want %v", got, want)
+	}
+}
+
+func TestBatcherLimitsFromBlock(t *testing.T) {
+	b := &batcher{blockGasLimit: func(ctx context.Context) (uint64, error) { return 1000000, nil }}
+	txGasLimit, roundGasBudget, err := b.limits(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txGasLimit != 500000 || roundGasBudget != 1000000 {
+		t.Errorf("got limits %v and %v, want half of and the block gas limit", txGasLimit, roundGasBudget)
+	}
+}
+
+func TestTxManagerSendsByPriority(t *testing.T) {
+	var sentKeys [][]string
+	state := newPublicationState(nil)
+	now := time.Unix(10000, 0)
+	state.setPublished([]string{"ETH/USD", "BTC/USD"}, []float64{100, 100}, now.Add(-time.Minute))
+	m := &txManager{
+		update: func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (*types.Transaction, error) {
+			sentKeys = append(sentKeys, keys)
+			return types.NewTx(&types.LegacyTx{}), nil
+		},
+		// One key per transaction and two transactions per round.
+		batcher:        &batcher{estimate: estimateLinear, txGasLimit: 60000, roundGasBudget: 120000},
+		state:          state,
+		pending:        make(map[string]pendingValue),
+		initialBackoff: time.Second,
+		maxBackoff:     10 * time.Second,
+	}
+
+	// SOL/USD was never published, ETH/USD deviates by 10 permille, BTC/USD is unchanged.
+	m.enqueue(
+		context.Background(),
+		[]string{"BTC/USD", "ETH/USD", "SOL/USD"},
+		[]pendingValue{{value: big.NewInt(100), published: 100}, {value: big.NewInt(101), published: 101}, {value: big.NewInt(20), published: 20}},
+		now.Unix(),
+	)
+	if want := [][]string{{"SOL/USD"}, {"ETH/USD"}}; !reflect.DeepEqual(sentKeys, want) {
+		t.Errorf("got %v, want %v", sentKeys, want)
+	}
+	if _, ok := m.pending["BTC/USD"]; !ok || len(m.pending) != 1 || m.retry == nil {
+		t.Error("BTC/USD must be deferred to the next round")
+	}
+	m.onRetry(context.Background())
+	if len(sentKeys) != 3 || len(m.pending) != 0 {
+		t.Errorf("deferred key must be sent in the next round, got %v", sentKeys)
+	}
+}
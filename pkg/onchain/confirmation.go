@@ -0,0 +1,184 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Reasons of failed verifications as exported by feeder_oracle_verification_failures_total.
+const (
+	verificationReverted = "reverted"
+	verificationEvent    = "event"
+	verificationReadback = "readback"
+)
+
+// sentUpdate is the content of an oracle update transaction.
+type sentUpdate struct {
+	keys      []string
+	values    []*big.Int
+	timestamp int64
+}
+
+// minedTx is a mined oracle update waiting for confirmations.
+type minedTx struct {
+	itx     *inflightTx
+	receipt *types.Receipt
+}
+
+// verificationFailure is a key whose update did not arrive in the oracle as sent.
+type verificationFailure struct {
+	key    string
+	reason string
+}
+
+// confirmationTracker verifies mined oracle updates once TX_CONFIRMATIONS blocks, including the block of the
+// transaction, are mined. A verified update has a successful receipt, an OracleUpdate event with the sent value
+// for each key, and the oracle's getValue returns the sent value, unless a newer update superseded it.
+// Keys that fail verification are logged, exported as metrics and read from the oracle again by the
+// publication policy. Transactions whose receipt vanishes in a reorg are handed back to the nonceManager.
+// All methods must be called from the goroutine running OracleUpdateExecutor.
+type confirmationTracker struct {
+	nodes         *NodePool
+	state         *publicationState
+	confirmations uint64
+	mined         []minedTx
+}
+
+func newConfirmationTracker(nodes *NodePool, state *publicationState) (*confirmationTracker, error) {
+	confirmations, err := strconv.ParseUint(utils.Getenv("TX_CONFIRMATIONS", "1"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse TX_CONFIRMATIONS: %w", err)
+	}
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	return &confirmationTracker{
+		nodes:         nodes,
+		state:         state,
+		confirmations: confirmations,
+	}, nil
+}
+
+// add records that @itx was mined with @receipt.
+func (c *confirmationTracker) add(itx *inflightTx, receipt *types.Receipt) {
+	c.mined = append(c.mined, minedTx{itx: itx, receipt: receipt})
+}
+
+// check verifies all mined transactions with enough confirmations. It returns the transactions that were
+// reorged out of the chain.
+func (c *confirmationTracker) check(ctx context.Context) []*inflightTx {
+	if len(c.mined) == 0 {
+		return nil
+	}
+	client := c.nodes.Active().Client
+	callCtx, cancel := context.WithTimeout(ctx, c.nodes.timeout)
+	head, err := client.BlockNumber(callCtx)
+	cancel()
+	if err != nil {
+		log.Warnf("updater - BlockNumber: %v.", err)
+		return nil
+	}
+
+	var (
+		waiting []minedTx
+		reorged []*inflightTx
+	)
+	for _, mtx := range c.mined {
+		if head+1 < mtx.receipt.BlockNumber.Uint64()+c.confirmations {
+			waiting = append(waiting, mtx)
+			continue
+		}
+		callCtx, cancel := context.WithTimeout(ctx, c.nodes.timeout)
+		receipt, err := client.TransactionReceipt(callCtx, mtx.receipt.TxHash)
+		cancel()
+		if errors.Is(err, ethereum.NotFound) {
+			log.Warnf("updater - Transaction 0x%x with nonce %v was reorged out of block %v.", mtx.receipt.TxHash, mtx.itx.nonce, mtx.receipt.BlockNumber)
+			oracleReorgedTxs.Inc()
+			reorged = append(reorged, mtx.itx)
+			continue
+		}
+		if err != nil {
+			log.Warnf("updater - TransactionReceipt of 0x%x: %v.", mtx.receipt.TxHash, err)
+			waiting = append(waiting, mtx)
+			continue
+		}
+		if receipt.BlockHash != mtx.receipt.BlockHash {
+			// The transaction was mined again in another block after a reorg.
+			log.Warnf("updater - Transaction 0x%x moved from block %v to %v.", receipt.TxHash, mtx.receipt.BlockNumber, receipt.BlockNumber)
+			oracleReorgedTxs.Inc()
+			waiting = append(waiting, minedTx{itx: mtx.itx, receipt: receipt})
+			continue
+		}
+		c.verify(ctx, mtx.itx.update, receipt)
+	}
+	c.mined = waiting
+	return reorged
+}
+
+// verify compares @update with the events in @receipt and with the values in the oracle.
+func (c *confirmationTracker) verify(ctx context.Context, update sentUpdate, receipt *types.Receipt) []verificationFailure {
+	var failures []verificationFailure
+	fail := func(key string, reason string) {
+		failures = append(failures, verificationFailure{key: key, reason: reason})
+		oracleVerificationFailures.WithLabelValues(key, reason).Inc()
+		c.state.forget(key)
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		for _, key := range update.keys {
+			log.Errorf("updater - Transaction 0x%x reverted in block %v. %s was not updated.", receipt.TxHash, receipt.BlockNumber, key)
+			fail(key, verificationReverted)
+		}
+		return failures
+	}
+
+	contract := c.nodes.Active().Contract
+	events := make(map[string]*big.Int)
+	for _, l := range receipt.Logs {
+		event, err := contract.ParseOracleUpdate(*l)
+		if err != nil {
+			// Not an OracleUpdate event.
+			continue
+		}
+		if event.Timestamp.Cmp(big.NewInt(update.timestamp)) == 0 {
+			events[event.Key] = event.Value
+		}
+	}
+
+	for i, key := range update.keys {
+		sent := update.values[i]
+		if value, ok := events[key]; !ok || value.Cmp(sent) != 0 {
+			log.Errorf("updater - Transaction 0x%x emitted no OracleUpdate of %s with value %v at %v.", receipt.TxHash, key, sent, update.timestamp)
+			fail(key, verificationEvent)
+			continue
+		}
+		callCtx, cancel := context.WithTimeout(ctx, c.nodes.timeout)
+		value, timestamp, err := contract.GetValue(&bind.CallOpts{Context: callCtx}, key)
+		cancel()
+		if err != nil {
+			log.Warnf("updater - GetValue of %s: %v.", key, err)
+			continue
+		}
+		if timestamp.Cmp(big.NewInt(update.timestamp)) > 0 {
+			// A newer update of the key was mined since.
+			continue
+		}
+		if value.Cmp(sent) != 0 || timestamp.Cmp(big.NewInt(update.timestamp)) != 0 {
+			log.Errorf("updater - Oracle returns %v at %v for %s, but transaction 0x%x wrote %v at %v.", value, timestamp, key, receipt.TxHash, sent, update.timestamp)
+			fail(key, verificationReadback)
+		}
+	}
+	if len(failures) == 0 {
+		log.Infof("updater - Transaction 0x%x with %v keys confirmed in block %v.", receipt.TxHash, len(update.keys), receipt.BlockNumber)
+		oracleConfirmedTxs.Inc()
+	}
+	return failures
+}
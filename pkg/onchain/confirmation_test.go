@@ -0,0 +1,87 @@
+package onchain
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestConfirmationTrackerVerify(t *testing.T) {
+	parsed, err := diaOracleV2MultiupdateService.DiaOracleV2MultiupdateServiceMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := parsed.Events["OracleUpdate"]
+	data, err := event.Inputs.Pack("BTC/USD", big.NewInt(100), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The oracle returns another value of BTC/USD than the one written.
+	getValue, err := parsed.Methods["getValue"].Outputs.Pack(big.NewInt(99), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &fakeNode{blockNumber: "0x64", receipts: make(map[string]*types.Receipt), callResult: hexutil.Encode(getValue)}
+	pool := newTestNodePool(t, node)
+	state := newPublicationState(nil)
+	state.setPublished([]string{"BTC/USD", "ETH/USD"}, []float64{1e-6, 2e-6}, time.Unix(1000, 0))
+	c := &confirmationTracker{nodes: pool, state: state, confirmations: 3}
+
+	receipt := &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      common.HexToHash("0xaa"),
+		BlockHash:   common.HexToHash("0xbb"),
+		BlockNumber: big.NewInt(99),
+		// ETH/USD emitted no event.
+		Logs: []*types.Log{{Topics: []common.Hash{event.ID}, Data: data}},
+	}
+	node.receipts[receipt.TxHash.Hex()] = receipt
+	itx := &inflightTx{nonce: 5, update: sentUpdate{keys: []string{"BTC/USD", "ETH/USD"}, values: []*big.Int{big.NewInt(100), big.NewInt(200)}, timestamp: 1000}}
+	c.add(itx, receipt)
+
+	if reorged := c.check(context.Background()); len(reorged) != 0 || len(c.mined) != 1 {
+		t.Fatal("transaction with 2 confirmations must wait for the third")
+	}
+
+	failures := c.verify(context.Background(), itx.update, receipt)
+	want := []verificationFailure{{key: "BTC/USD", reason: verificationReadback}, {key: "ETH/USD", reason: verificationEvent}}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("got failures %v, want %v", failures, want)
+	}
+	if len(state.published) != 0 {
+		t.Error("keys failing verification must be read from the oracle again")
+	}
+
+	// The transaction is reorged out before it is confirmed.
+	delete(node.receipts, receipt.TxHash.Hex())
+	node.blockNumber = "0x65"
+	if reorged := c.check(context.Background()); len(reorged) != 1 || reorged[0] != itx || len(c.mined) != 0 {
+		t.Error("reorged transaction must be handed back")
+	}
+}
+
+func TestConfirmationTrackerVerifyReverted(t *testing.T) {
+	pool := newTestNodePool(t, &fakeNode{blockNumber: "0x64"})
+	c := &confirmationTracker{nodes: pool, state: newPublicationState(nil), confirmations: 1}
+	receipt := &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(100)}
+	failures := c.verify(context.Background(), sentUpdate{keys: []string{"BTC/USD"}, values: []*big.Int{big.NewInt(100)}, timestamp: 1000}, receipt)
+	if len(failures) != 1 || failures[0].reason != verificationReverted {
+		t.Errorf("got failures %v, want BTC/USD reverted", failures)
+	}
+}
+
+func TestNewConfirmationTrackerInvalid(t *testing.T) {
+	for _, confirmations := range []string{"1.5", "-1"} {
+		t.Setenv("TX_CONFIRMATIONS", confirmations)
+		if _, err := newConfirmationTracker(nil, nil); err == nil {
+			t.Errorf("TX_CONFIRMATIONS=%s: expected an error", confirmations)
+		}
+	}
+}
@@ -10,13 +10,15 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+// DeployOrBindContract binds the oracle at @deployedContract to @conn and to each of @connBackups.
+// If @deployedContract is empty, a new oracle is deployed through @conn.
 func DeployOrBindContract(
 	deployedContract string,
 	conn *ethclient.Client,
-	connBackup *ethclient.Client,
+	connBackups []*ethclient.Client,
 	auth *bind.TransactOpts,
 	contract **diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService,
-	contractBackup **diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService) error {
+	contractBackups *[]*diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService) error {
 	var err error
 	if deployedContract != "" {
 		// bind primary and backup
@@ -24,9 +26,12 @@ func DeployOrBindContract(
 		if err != nil {
 			return err
 		}
-		*contractBackup, err = diaOracleV2MultiupdateService.NewDiaOracleV2MultiupdateService(common.HexToAddress(deployedContract), connBackup)
-		if err != nil {
-			return err
+		for _, connBackup := range connBackups {
+			contractBackup, err := diaOracleV2MultiupdateService.NewDiaOracleV2MultiupdateService(common.HexToAddress(deployedContract), connBackup)
+			if err != nil {
+				return err
+			}
+			*contractBackups = append(*contractBackups, contractBackup)
 		}
 	} else {
 		// deploy contract
@@ -39,10 +44,13 @@ func DeployOrBindContract(
 		}
 		log.Infof("Contract pending deploy: 0x%x.", addr)
 		log.Infof("Transaction waiting to be mined: 0x%x.", tx.Hash())
-		// bind backup
-		*contractBackup, err = diaOracleV2MultiupdateService.NewDiaOracleV2MultiupdateService(addr, connBackup)
-		if err != nil {
-			return err
+		// bind backups
+		for _, connBackup := range connBackups {
+			contractBackup, err := diaOracleV2MultiupdateService.NewDiaOracleV2MultiupdateService(addr, connBackup)
+			if err != nil {
+				return err
+			}
+			*contractBackups = append(*contractBackups, contractBackup)
 		}
 		time.Sleep(180000 * time.Millisecond)
 	}
@@ -0,0 +1,190 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// dryRunGasLimit is the gas limit of dry-run transactions whose gas cannot be estimated if TX_GAS_LIMIT is 0.
+const dryRunGasLimit = 30000000
+
+// dryRunValue is a value decoded from the call data of a dry-run transaction.
+type dryRunValue struct {
+	Key       string   `json:"key"`
+	Value     *big.Int `json:"value"`
+	Timestamp *big.Int `json:"timestamp"`
+}
+
+// dryRunRecord describes an oracle update that was built and signed, but not sent.
+type dryRunRecord struct {
+	Time   time.Time     `json:"time"`
+	From   string        `json:"from"`
+	Nonce  uint64        `json:"nonce"`
+	Values []dryRunValue `json:"values"`
+	// Gas is 0 if the gas could not be estimated.
+	Gas            uint64        `json:"gas"`
+	GasPrice       *big.Int      `json:"gasPrice,omitempty"`
+	GasTipCap      *big.Int      `json:"gasTipCap,omitempty"`
+	GasFeeCap      *big.Int      `json:"gasFeeCap,omitempty"`
+	Hash           common.Hash   `json:"hash"`
+	RawTransaction hexutil.Bytes `json:"rawTransaction"`
+}
+
+// dryRun builds and signs oracle updates like updateOracleMultiValues, but logs them and writes them to
+// DRY_RUN_OUTPUT as JSON lines instead of broadcasting them. Nonce, fees and gas are taken from the active node
+// if it is reachable. Otherwise the transaction is signed with nonce 0, no fees and a gas limit of TX_GAS_LIMIT
+// or dryRunGasLimit, so a dry run needs neither a funded key nor a reachable chain. Failures of the node are not
+// reported to the NodePool.
+type dryRun struct {
+	nodes    *NodePool
+	auth     *bind.TransactOpts
+	gas      GasStrategy
+	gasLimit uint64
+	output   *os.File
+}
+
+func newDryRun(nodes *NodePool, auth *bind.TransactOpts, gas GasStrategy) (*dryRun, error) {
+	gasLimit, err := strconv.ParseUint(utils.Getenv("TX_GAS_LIMIT", "0"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse TX_GAS_LIMIT: %w", err)
+	}
+	if gasLimit == 0 {
+		gasLimit = dryRunGasLimit
+	}
+	d := &dryRun{nodes: nodes, auth: auth, gas: gas, gasLimit: gasLimit}
+	if path := os.Getenv("DRY_RUN_OUTPUT"); path != "" {
+		output, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("open DRY_RUN_OUTPUT: %w", err)
+		}
+		d.output = output
+	}
+	return d, nil
+}
+
+// update builds and signs a transaction writing @values of @keys at @timestamp and records it.
+func (d *dryRun) update(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (*types.Transaction, error) {
+	cValues, err := packValues(values, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	node := d.nodes.Active()
+
+	opts := &bind.TransactOpts{
+		From:     d.auth.From,
+		Signer:   d.auth.Signer,
+		Nonce:    new(big.Int),
+		GasPrice: new(big.Int),
+		GasLimit: d.gasLimit,
+		NoSend:   true,
+	}
+	callCtx, cancel := context.WithTimeout(ctx, d.nodes.timeout)
+	defer cancel()
+	if nonce, err := node.Client.PendingNonceAt(callCtx, d.auth.From); err == nil {
+		opts.Nonce.SetUint64(nonce)
+	} else {
+		log.Warnf("updater - Dry run without nonce: %v.", err)
+	}
+	if fees, err := d.gas.Fees(callCtx, node.Client); err == nil {
+		opts.GasPrice, opts.GasTipCap, opts.GasFeeCap = fees.GasPrice, fees.GasTipCap, fees.GasFeeCap
+	} else {
+		log.Warnf("updater - Dry run without gas fees: %v.", err)
+	}
+	gas, err := estimateOracleMultiValues(ctx, d.nodes, d.auth, keys, values, timestamp)
+	if err == nil {
+		opts.GasLimit = gas
+	} else {
+		log.Warnf("updater - Dry run without gas estimate: %v.", err)
+	}
+
+	tx, err := node.Contract.SetMultipleValues(opts, keys, cValues)
+	if err != nil {
+		return nil, err
+	}
+	record, err := d.record(tx, gas)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range record.Values {
+		log.Infof("updater - Dry run: %s = %v at %v.", value.Key, value.Value, value.Timestamp)
+	}
+	log.Infof("updater - Dry run: transaction 0x%x with nonce %v and %v keys using %v gas not sent.", tx.Hash(), tx.Nonce(), len(keys), gas)
+	if d.output != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.output.Write(append(line, '\n')); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// record returns the description of the signed transaction @tx with estimated @gas. The values are decoded
+// from the call data of @tx, such that the record shows what the oracle would store.
+func (d *dryRun) record(tx *types.Transaction, gas uint64) (dryRunRecord, error) {
+	parsed, err := diaOracleV2MultiupdateService.DiaOracleV2MultiupdateServiceMetaData.GetAbi()
+	if err != nil {
+		return dryRunRecord{}, err
+	}
+	method, err := parsed.MethodById(tx.Data())
+	if err != nil {
+		return dryRunRecord{}, err
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return dryRunRecord{}, err
+	}
+	keys, ok := args[0].([]string)
+	cValues, ok2 := args[1].([]*big.Int)
+	if !ok || !ok2 || len(keys) != len(cValues) {
+		return dryRunRecord{}, fmt.Errorf("unexpected arguments of %s", method.Name)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return dryRunRecord{}, err
+	}
+
+	record := dryRunRecord{
+		Time:           time.Now(),
+		From:           d.auth.From.Hex(),
+		Nonce:          tx.Nonce(),
+		Gas:            gas,
+		Hash:           tx.Hash(),
+		RawTransaction: raw,
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		record.GasTipCap, record.GasFeeCap = tx.GasTipCap(), tx.GasFeeCap()
+	} else {
+		record.GasPrice = tx.GasPrice()
+	}
+	for i, key := range keys {
+		record.Values = append(record.Values, dryRunValue{
+			Key:       key,
+			Value:     new(big.Int).Rsh(cValues[i], 128),
+			Timestamp: new(big.Int).And(cValues[i], maxUint128),
+		})
+	}
+	return record, nil
+}
+
+// Close closes the output file.
+func (d *dryRun) Close() error {
+	if d.output == nil {
+		return nil
+	}
+	return d.output.Close()
+}
@@ -0,0 +1,103 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestDryRunRecordsWithoutSending(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "dryrun.jsonl")
+	t.Setenv("DRY_RUN_OUTPUT", output)
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, node := range []*fakeNode{
+		{blockNumber: "0x64", pendingNonce: "0x7", gasPrice: "0x3b9aca00"},
+		// Unreachable chain.
+		{down: true},
+	} {
+		pool := newTestNodePool(t, node)
+		d, err := newDryRun(pool, auth, legacyGasStrategy{multiplier: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := d.update(context.Background(), []string{"BTC/USD", "ETH/USD"}, []*big.Int{big.NewInt(6500000000000), big.NewInt(350000000000)}, 1700000000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Close()
+		if len(node.received) != 0 {
+			t.Errorf("dry run sent %v transactions", len(node.received))
+		}
+		if tx.Gas() != dryRunGasLimit {
+			t.Errorf("gas limit %v, want %v", tx.Gas(), dryRunGasLimit)
+		}
+		if !pool.healthy[0] {
+			t.Error("dry run marked the node unhealthy")
+		}
+	}
+
+	contents, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("recorded %v transactions, want 2", len(lines))
+	}
+	for i, wantNonce := range []uint64{7, 0} {
+		var record dryRunRecord
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Nonce != wantNonce {
+			t.Errorf("record %v: nonce %v, want %v", i, record.Nonce, wantNonce)
+		}
+		if record.From != auth.From.Hex() {
+			t.Errorf("record %v: from %s, want %s", i, record.From, auth.From.Hex())
+		}
+		if len(record.Values) != 2 ||
+			record.Values[0].Key != "BTC/USD" || record.Values[0].Value.Int64() != 6500000000000 ||
+			record.Values[1].Key != "ETH/USD" || record.Values[1].Value.Int64() != 350000000000 ||
+			record.Values[1].Timestamp.Int64() != 1700000000 {
+			t.Errorf("record %v: values %+v", i, record.Values)
+		}
+	}
+}
+
+func TestDryRunTxGasLimit(t *testing.T) {
+	t.Setenv("TX_GAS_LIMIT", "5000000")
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDryRun(newTestNodePool(t, &fakeNode{down: true}), auth, legacyGasStrategy{multiplier: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := d.update(context.Background(), []string{"BTC/USD"}, []*big.Int{big.NewInt(6500000000000)}, 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Gas() != 5000000 {
+		t.Errorf("gas limit %v, want 5000000", tx.Gas())
+	}
+}
@@ -0,0 +1,108 @@
+package onchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+)
+
+const (
+	// defaultDecimals is the number of decimals of a value in the oracle unless configured otherwise.
+	defaultDecimals = 8
+	// maxDecimals bounds the configurable decimals, such that 1 is still representable in 128 bits.
+	maxDecimals = 38
+)
+
+var (
+	// maxUint128 is the largest value and timestamp DIAOracleV2 stores.
+	maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+	errNotFinite      = errors.New("value is not finite")
+	errNotPositive    = errors.New("value is not positive")
+	errBelowPrecision = errors.New("value rounds to 0 at the configured decimals")
+	errOutOfRange     = errors.New("value does not fit into 128 bits")
+)
+
+// DecimalsFromEnv returns the number of decimals of the feed with @key of the asset with @symbol. It is given by
+// DECIMALS_<KEY>, where <KEY> is @key in envKey form, which defaults to DECIMALS_<SYMBOL>, which defaults to
+// DECIMALS, which defaults to 8.
+func DecimalsFromEnv(key string, symbol string) int {
+	decimals := getenvDecimals("DECIMALS", defaultDecimals)
+	decimals = getenvDecimals("DECIMALS_"+strings.ToUpper(symbol), decimals)
+	return getenvDecimals("DECIMALS_"+envKey(key), decimals)
+}
+
+func getenvDecimals(key string, defaultValue int) int {
+	decimals, err := strconv.Atoi(utils.Getenv(key, strconv.Itoa(defaultValue)))
+	if err != nil || decimals < 0 || decimals > maxDecimals {
+		log.Errorf("Parse %s: must be an integer between 0 and %v.", key, maxDecimals)
+		return defaultValue
+	}
+	return decimals
+}
+
+// scale returns 10^@decimals.
+func scale(decimals int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// encodeValue returns @value as a fixed-point integer with @decimals, rounded half up.
+// Values that are not finite or not positive, or that do not fit into 128 bits, are rejected.
+func encodeValue(value float64, decimals int) (*big.Int, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, errNotFinite
+	}
+	if value <= 0 {
+		return nil, fmt.Errorf("%w: %v", errNotPositive, value)
+	}
+	// Scale the shortest decimal representation of @value exactly, such that 0.1 encodes as 10^(decimals-1)
+	// rather than picking up the binary rounding error of the float.
+	scaled, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'g', -1, 64))
+	scaled.Mul(scaled, new(big.Rat).SetInt(scale(decimals)))
+	scaled.Add(scaled, big.NewRat(1, 2))
+	encoded := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	if encoded.Sign() == 0 {
+		return nil, fmt.Errorf("%w: %v with %v decimals", errBelowPrecision, value, decimals)
+	}
+	if encoded.Cmp(maxUint128) > 0 {
+		return nil, fmt.Errorf("%w: %v with %v decimals", errOutOfRange, value, decimals)
+	}
+	return encoded, nil
+}
+
+// decodeValue returns the fixed-point integer @value with @decimals as float.
+func decodeValue(value *big.Int, decimals int) float64 {
+	decoded, _ := new(big.Float).Quo(new(big.Float).SetInt(value), new(big.Float).SetInt(scale(decimals))).Float64()
+	return decoded
+}
+
+// packValue returns @value and @timestamp packed into the uint256 argument of setMultipleValues,
+// with @value in the upper and @timestamp in the lower 128 bits.
+func packValue(value *big.Int, timestamp int64) (*big.Int, error) {
+	if value.Sign() < 0 || value.Cmp(maxUint128) > 0 {
+		return nil, fmt.Errorf("%w: %v", errOutOfRange, value)
+	}
+	if timestamp < 0 {
+		return nil, fmt.Errorf("timestamp %v is negative", timestamp)
+	}
+	packed := new(big.Int).Lsh(value, 128)
+	return packed.Or(packed, big.NewInt(timestamp)), nil
+}
+
+// packValues returns the arguments of setMultipleValues for @values at @timestamp.
+func packValues(values []*big.Int, timestamp int64) ([]*big.Int, error) {
+	cValues := make([]*big.Int, len(values))
+	for i, value := range values {
+		cValue, err := packValue(value, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		cValues[i] = cValue
+	}
+	return cValues, nil
+}
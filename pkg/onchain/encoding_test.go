@@ -0,0 +1,77 @@
+package onchain
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestEncodeValue(t *testing.T) {
+	cases := []struct {
+		value    float64
+		decimals int
+		want     string
+		err      error
+	}{
+		{value: 1.5, decimals: 8, want: "150000000"},
+		{value: 0.1, decimals: 18, want: "100000000000000000"},
+		{value: 64123.456789, decimals: 8, want: "6412345678900"},
+		{value: 1e-12, decimals: 18, want: "1000000"},
+		{value: 1e30, decimals: 8, want: "100000000000000000000000000000000000000"},
+		{value: 1e-12, decimals: 8, err: errBelowPrecision},
+		{value: 1e31, decimals: 8, err: errOutOfRange},
+		{value: 0, decimals: 8, err: errNotPositive},
+		{value: -1, decimals: 8, err: errNotPositive},
+		{value: math.NaN(), decimals: 8, err: errNotFinite},
+		{value: math.Inf(1), decimals: 8, err: errNotFinite},
+	}
+	for _, c := range cases {
+		got, err := encodeValue(c.value, c.decimals)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("%v with %v decimals: got error %v, want %v", c.value, c.decimals, err, c.err)
+			}
+			continue
+		}
+		if err != nil || got.String() != c.want {
+			t.Errorf("%v with %v decimals: got %v (%v), want %s", c.value, c.decimals, got, err, c.want)
+		}
+	}
+}
+
+func TestPackValue(t *testing.T) {
+	value := new(big.Int).Set(maxUint128)
+	packed, err := packValue(value, 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if new(big.Int).Rsh(packed, 128).Cmp(value) != 0 || new(big.Int).And(packed, maxUint128).Int64() != 1700000000 {
+		t.Errorf("got %x, want value in the upper and timestamp in the lower 128 bits", packed)
+	}
+	if _, err := packValue(new(big.Int).Add(maxUint128, big.NewInt(1)), 1700000000); !errors.Is(err, errOutOfRange) {
+		t.Errorf("got %v for a value above 128 bits, want errOutOfRange", err)
+	}
+	if _, err := packValue(big.NewInt(1), -1); err == nil {
+		t.Error("negative timestamp must be rejected")
+	}
+}
+
+func TestDecimalsFromEnv(t *testing.T) {
+	t.Setenv("DECIMALS", "18")
+	t.Setenv("DECIMALS_BTC", "8")
+	t.Setenv("DECIMALS_BTC_USD_100000", "12")
+	t.Setenv("DECIMALS_ETH", "99")
+	if got := DecimalsFromEnv("BTC/USD", "BTC"); got != 8 {
+		t.Errorf("BTC/USD: got %v, want 8", got)
+	}
+	if got := DecimalsFromEnv("BTC/USD@100000", "BTC"); got != 12 {
+		t.Errorf("BTC/USD@100000: got %v, want 12", got)
+	}
+	if got := DecimalsFromEnv("SOL/USD", "SOL"); got != 18 {
+		t.Errorf("SOL/USD: got %v, want 18", got)
+	}
+	if got := DecimalsFromEnv("ETH/USD", "ETH"); got != 18 {
+		t.Errorf("ETH/USD with invalid decimals: got %v, want the default 18", got)
+	}
+}
@@ -0,0 +1,222 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/tidwall/gjson"
+)
+
+// Names of the gas strategies selectable by GAS_STRATEGY.
+const (
+	gasStrategyLegacy  = "legacy"
+	gasStrategyEIP1559 = "eip1559"
+	gasStrategyAstar   = "astar"
+)
+
+// errGasPriceTooHigh is returned for updates that would pay more than MAX_GAS_PRICE_GWEI. They are delayed
+// until the gas price drops.
+var errGasPriceTooHigh = errors.New("gas price above MAX_GAS_PRICE_GWEI")
+
+// GasFees are the fees of a transaction. Legacy transactions set GasPrice, dynamic-fee (EIP-1559) transactions
+// set GasTipCap and GasFeeCap. BaseFee is the base fee of the latest block, if known.
+type GasFees struct {
+	GasPrice  *big.Int
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	BaseFee   *big.Int
+}
+
+// Dynamic returns true for the fees of a dynamic-fee transaction.
+func (fees GasFees) Dynamic() bool {
+	return fees.GasFeeCap != nil
+}
+
+// Max returns the maximal price per gas a transaction with @fees pays.
+func (fees GasFees) Max() *big.Int {
+	if fees.Dynamic() {
+		return fees.GasFeeCap
+	}
+	return fees.GasPrice
+}
+
+// bump returns @fees increased by @percent, as required for replacing a pending transaction.
+func (fees GasFees) bump(percent float64) GasFees {
+	if fees.Dynamic() {
+		fees.GasTipCap = bumpGas(fees.GasTipCap, percent)
+		fees.GasFeeCap = bumpGas(fees.GasFeeCap, percent)
+		return fees
+	}
+	fees.GasPrice = bumpGas(fees.GasPrice, percent)
+	return fees
+}
+
+// feesOf returns the fees of @tx.
+func feesOf(tx *types.Transaction) GasFees {
+	if tx.Type() == types.DynamicFeeTxType {
+		return GasFees{GasTipCap: tx.GasTipCap(), GasFeeCap: tx.GasFeeCap()}
+	}
+	return GasFees{GasPrice: tx.GasPrice()}
+}
+
+// bumpGas returns @value increased by @percent, rounded up.
+func bumpGas(value *big.Int, percent float64) *big.Int {
+	bumped := new(big.Int).Mul(value, big.NewInt(int64(percent*100)+10000))
+	bumped.Add(bumped, big.NewInt(9999))
+	return bumped.Div(bumped, big.NewInt(10000))
+}
+
+// mulGas returns @value multiplied by @factor.
+func mulGas(value *big.Int, factor float64) *big.Int {
+	product, _ := new(big.Float).Mul(new(big.Float).SetInt(value), big.NewFloat(factor)).Int(nil)
+	return product
+}
+
+// gweiToWei converts @gwei to wei.
+func gweiToWei(gwei float64) *big.Int {
+	return mulGas(big.NewInt(1000000000), gwei)
+}
+
+// gweiFloat converts @wei to gwei.
+func gweiFloat(wei *big.Int) float64 {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1000000000)).Float64()
+	return gwei
+}
+
+// GasStrategy determines the fees of oracle update transactions.
+type GasStrategy interface {
+	Fees(ctx context.Context, client *ethclient.Client) (GasFees, error)
+}
+
+// GasStrategyFromEnv returns the gas strategy selected by GAS_STRATEGY:
+//   - legacy: legacy transactions paying the node's suggested gas price times GAS_PRICE_MULTIPLIER (default 1.1).
+//   - eip1559 (default): dynamic-fee transactions with the node's suggested tip times GAS_TIP_MULTIPLIER (default 1),
+//     or GAS_TIP_GWEI if set, and a fee cap of GAS_BASE_FEE_MULTIPLIER (default 2) times the base fee plus the tip.
+//     On chains without a base fee, it falls back to legacy.
+//   - astar: legacy transactions paying the price given by Astar's gas API at ASTAR_GAS_API_URL.
+func GasStrategyFromEnv() (GasStrategy, error) {
+	legacy := legacyGasStrategy{multiplier: utils.GetenvFloat("GAS_PRICE_MULTIPLIER", 1.1)}
+	switch name := utils.Getenv("GAS_STRATEGY", gasStrategyEIP1559); name {
+	case gasStrategyLegacy:
+		return legacy, nil
+	case gasStrategyEIP1559:
+		return eip1559GasStrategy{
+			tipMultiplier:     utils.GetenvFloat("GAS_TIP_MULTIPLIER", 1),
+			tip:               gweiToWei(utils.GetenvFloat("GAS_TIP_GWEI", 0)),
+			baseFeeMultiplier: utils.GetenvFloat("GAS_BASE_FEE_MULTIPLIER", 2),
+			fallback:          legacy,
+		}, nil
+	case gasStrategyAstar:
+		return astarGasStrategy{url: utils.Getenv("ASTAR_GAS_API_URL", "https://gas.astar.network/api/gasnow?network=astar")}, nil
+	default:
+		return nil, fmt.Errorf("unknown GAS_STRATEGY %s", name)
+	}
+}
+
+// legacyGasStrategy pays the suggested gas price times multiplier.
+type legacyGasStrategy struct {
+	multiplier float64
+}
+
+func (strategy legacyGasStrategy) Fees(ctx context.Context, client *ethclient.Client) (GasFees, error) {
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return GasFees{}, err
+	}
+	return GasFees{GasPrice: mulGas(gasPrice, strategy.multiplier)}, nil
+}
+
+// eip1559GasStrategy pays a tip on top of the base fee. A fixed tip overrides the suggested one.
+type eip1559GasStrategy struct {
+	tipMultiplier     float64
+	tip               *big.Int
+	baseFeeMultiplier float64
+	fallback          GasStrategy
+}
+
+func (strategy eip1559GasStrategy) Fees(ctx context.Context, client *ethclient.Client) (GasFees, error) {
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return GasFees{}, err
+	}
+	if header.BaseFee == nil {
+		// The chain does not support dynamic-fee transactions.
+		return strategy.fallback.Fees(ctx, client)
+	}
+	tip := strategy.tip
+	if tip.Sign() == 0 {
+		suggested, err := client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return GasFees{}, err
+		}
+		tip = mulGas(suggested, strategy.tipMultiplier)
+	}
+	return GasFees{
+		GasTipCap: tip,
+		GasFeeCap: new(big.Int).Add(mulGas(header.BaseFee, strategy.baseFeeMultiplier), tip),
+		BaseFee:   header.BaseFee,
+	}, nil
+}
+
+// astarGasStrategy pays the fast gas price of Astar's gas API.
+type astarGasStrategy struct {
+	url string
+}
+
+func (strategy astarGasStrategy) Fees(ctx context.Context, client *ethclient.Client) (GasFees, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strategy.url, nil)
+	if err != nil {
+		return GasFees{}, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return GasFees{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return GasFees{}, fmt.Errorf("gas API returned %s", response.Status)
+	}
+	contents, err := io.ReadAll(response.Body)
+	if err != nil {
+		return GasFees{}, err
+	}
+	gasSuggestion := gjson.Get(string(contents), "data.fast")
+	if !gasSuggestion.Exists() {
+		return GasFees{}, errors.New("gas API returned no gas price")
+	}
+	return GasFees{GasPrice: big.NewInt(gasSuggestion.Int())}, nil
+}
+
+// limitFees caps @fees at @maxGasPrice. A dynamic-fee transaction keeps paying the base fee plus its tip,
+// but its fee cap is lowered to @maxGasPrice. It returns errGasPriceTooHigh if the transaction would pay more.
+// A nil @maxGasPrice disables the limit.
+func limitFees(fees GasFees, maxGasPrice *big.Int) (GasFees, error) {
+	if maxGasPrice == nil {
+		return fees, nil
+	}
+	if !fees.Dynamic() {
+		if fees.GasPrice.Cmp(maxGasPrice) > 0 {
+			return fees, fmt.Errorf("%w: %v > %v", errGasPriceTooHigh, fees.GasPrice, maxGasPrice)
+		}
+		return fees, nil
+	}
+	if fees.GasTipCap.Cmp(maxGasPrice) > 0 {
+		return fees, fmt.Errorf("%w: tip %v > %v", errGasPriceTooHigh, fees.GasTipCap, maxGasPrice)
+	}
+	if fees.BaseFee != nil {
+		if price := new(big.Int).Add(fees.BaseFee, fees.GasTipCap); price.Cmp(maxGasPrice) > 0 {
+			return fees, fmt.Errorf("%w: %v > %v", errGasPriceTooHigh, price, maxGasPrice)
+		}
+	}
+	if fees.GasFeeCap.Cmp(maxGasPrice) > 0 {
+		fees.GasFeeCap = maxGasPrice
+	}
+	return fees, nil
+}
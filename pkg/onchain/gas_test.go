@@ -0,0 +1,40 @@
+package onchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestLimitFees(t *testing.T) {
+	max := big.NewInt(100)
+
+	if _, err := limitFees(GasFees{GasPrice: big.NewInt(101)}, max); !errors.Is(err, errGasPriceTooHigh) {
+		t.Errorf("legacy gas price above the maximum: got %v, want errGasPriceTooHigh", err)
+	}
+	if _, err := limitFees(GasFees{GasPrice: big.NewInt(101)}, nil); err != nil {
+		t.Errorf("no maximum: got %v", err)
+	}
+
+	// The fee cap is lowered as long as the base fee and the tip fit below the maximum.
+	fees, err := limitFees(GasFees{GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(160), BaseFee: big.NewInt(79)}, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasFeeCap.Cmp(max) != 0 || fees.GasTipCap.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("got tip %v and fee cap %v, want 2 and 100", fees.GasTipCap, fees.GasFeeCap)
+	}
+	if _, err := limitFees(GasFees{GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(200), BaseFee: big.NewInt(99)}, max); !errors.Is(err, errGasPriceTooHigh) {
+		t.Errorf("base fee and tip above the maximum: got %v, want errGasPriceTooHigh", err)
+	}
+}
+
+func TestGasFeesBump(t *testing.T) {
+	fees := GasFees{GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(205)}.bump(10)
+	if fees.GasTipCap.Cmp(big.NewInt(11)) != 0 || fees.GasFeeCap.Cmp(big.NewInt(226)) != 0 {
+		t.Errorf("got tip %v and fee cap %v, want 11 and 226", fees.GasTipCap, fees.GasFeeCap)
+	}
+	if !fees.Dynamic() || (GasFees{GasPrice: big.NewInt(1)}).Dynamic() {
+		t.Error("fees with a fee cap are dynamic, fees with a gas price are legacy")
+	}
+}
@@ -0,0 +1,147 @@
+package onchain
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	oracleUpdates = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_updates_total",
+			Help:      "Number of oracle update transactions sent.",
+		},
+	)
+	oracleUpdateFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_update_failures_total",
+			Help:      "Number of failed attempts to send an oracle update by error class.",
+		},
+		[]string{"class"},
+	)
+	oracleUpdateState = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "oracle_update_state",
+			Help:      "State of the oracle updater: 0 ok, 1 retrying after a retryable error, 2 retrying after a fatal error.",
+		},
+	)
+	oracleSupersededValues = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_superseded_values_total",
+			Help:      "Number of pending values replaced by a newer value of the same key before they were sent.",
+		},
+	)
+	oracleInvalidValues = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_invalid_values_total",
+			Help:      "Number of values rejected as not finite, not positive or out of range for the oracle.",
+		},
+		[]string{"key"},
+	)
+	oracleDeferredValues = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_deferred_values_total",
+			Help:      "Number of values deferred to the next round as they did not fit into the gas budget.",
+		},
+	)
+	oracleNonce = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "oracle_nonce",
+			Help:      "Nonce of the next oracle update transaction.",
+		},
+	)
+	oraclePendingTxs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "oracle_pending_transactions",
+			Help:      "Number of sent oracle update transactions that are not mined yet.",
+		},
+	)
+	oracleReplacedTxs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_replaced_transactions_total",
+			Help:      "Number of stuck oracle update transactions replaced with a higher gas price.",
+		},
+	)
+	oracleGasPrice = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "oracle_gas_price_gwei",
+			Help:      "Maximal gas price of the latest oracle update in gwei.",
+		},
+	)
+	oracleConfirmedTxs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_confirmed_transactions_total",
+			Help:      "Number of oracle update transactions confirmed and verified against the oracle.",
+		},
+	)
+	oracleReorgedTxs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_reorged_transactions_total",
+			Help:      "Number of mined oracle update transactions removed from their block by a reorg.",
+		},
+	)
+	oracleVerificationFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "oracle_verification_failures_total",
+			Help:      "Number of keys whose update was reverted, emitted no matching event or was not read back from the oracle.",
+		},
+		[]string{"key", "reason"},
+	)
+	rpcNodeActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "rpc_node_active",
+			Help:      "1 for the RPC node transactions are sent to, 0 otherwise.",
+		},
+		[]string{"node"},
+	)
+	rpcNodeHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "rpc_node_healthy",
+			Help:      "1 if the RPC node passed its last health check, 0 otherwise.",
+		},
+		[]string{"node"},
+	)
+	rpcNodeFailovers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "rpc_node_failovers_total",
+			Help:      "Number of switches of the active RPC node.",
+		},
+	)
+)
+
+// Metrics returns all prometheus collectors of the oracle updater.
+func Metrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		oracleUpdates,
+		oracleUpdateFailures,
+		oracleUpdateState,
+		oracleSupersededValues,
+		oracleInvalidValues,
+		oracleDeferredValues,
+		oracleNonce,
+		oraclePendingTxs,
+		oracleReplacedTxs,
+		oracleGasPrice,
+		oracleConfirmedTxs,
+		oracleReorgedTxs,
+		oracleVerificationFailures,
+		rpcNodeActive,
+		rpcNodeHealthy,
+		rpcNodeFailovers,
+	}
+}
@@ -0,0 +1,247 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Node is an RPC node with the oracle contract bound to it.
+type Node struct {
+	// Name identifies the node in logs and metrics, as URLs may contain API keys.
+	Name     string
+	Client   *ethclient.Client
+	Contract *diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService
+}
+
+// nodeErrors are errors of a node rather than of a transaction. They trigger the failover to the next node.
+var nodeErrors = []string{
+	"timeout",
+	"deadline exceeded",
+	"connection refused",
+	"connection reset",
+	"no such host",
+	"eof",
+	"too many requests",
+	"429",
+	"502",
+	"503",
+	"504",
+}
+
+func isNodeError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, nodeError := range nodeErrors {
+		if strings.Contains(message, nodeError) {
+			return true
+		}
+	}
+	return false
+}
+
+// NodePool sends transactions through the active node and fails over to the next healthy node in order of
+// priority. The active node is sticky: it is only replaced if it fails or if a health check, run every
+// NODE_HEALTH_CHECK_SECONDS, finds it unreachable or more than NODE_MAX_BLOCK_LAG blocks behind the best node.
+// With NODE_PREFER_PRIMARY, a health check also returns to the healthy node with the highest priority.
+type NodePool struct {
+	mu            sync.Mutex
+	nodes         []Node
+	healthy       []bool
+	active        int
+	checkInterval time.Duration
+	maxBlockLag   uint64
+	timeout       time.Duration
+	preferPrimary bool
+}
+
+// NewNodePool returns a pool of @nodes. The first node has the highest priority.
+func NewNodePool(nodes []Node) (*NodePool, error) {
+	maxBlockLag, err := strconv.ParseUint(utils.Getenv("NODE_MAX_BLOCK_LAG", "5"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse NODE_MAX_BLOCK_LAG: %w", err)
+	}
+	pool := &NodePool{
+		nodes:         nodes,
+		healthy:       make([]bool, len(nodes)),
+		checkInterval: time.Duration(utils.GetenvFloat("NODE_HEALTH_CHECK_SECONDS", 60)*1000) * time.Millisecond,
+		maxBlockLag:   maxBlockLag,
+		timeout:       time.Duration(utils.GetenvFloat("NODE_TIMEOUT_SECONDS", 10)*1000) * time.Millisecond,
+		preferPrimary: utils.Getenv("NODE_PREFER_PRIMARY", "false") == "true",
+	}
+	for i := range pool.healthy {
+		pool.healthy[i] = true
+	}
+	pool.setActive(0)
+	return pool, nil
+}
+
+// Run checks the health of all nodes periodically until @ctx is cancelled.
+func (pool *NodePool) Run(ctx context.Context) {
+	ticker := time.NewTicker(pool.checkInterval)
+	defer ticker.Stop()
+	for {
+		pool.checkHealth(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Active returns the node transactions are sent to.
+func (pool *NodePool) Active() Node {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.nodes[pool.active]
+}
+
+// checkHealth marks nodes as unhealthy if they do not respond or lag behind. If the active node is unhealthy,
+// the healthy node with the highest priority is activated, which counts as a failover. With @pool.preferPrimary,
+// the healthy node with the highest priority is activated in any case.
+func (pool *NodePool) checkHealth(ctx context.Context) {
+	blockNumbers := make([]uint64, len(pool.nodes))
+	reachable := make([]bool, len(pool.nodes))
+	var best uint64
+	for i, node := range pool.nodes {
+		callCtx, cancel := context.WithTimeout(ctx, pool.timeout)
+		blockNumber, err := node.Client.BlockNumber(callCtx)
+		cancel()
+		if err != nil {
+			log.Warnf("updater - Health check of node %s: %v.", node.Name, err)
+			continue
+		}
+		reachable[i] = true
+		blockNumbers[i] = blockNumber
+		if blockNumber > best {
+			best = blockNumber
+		}
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for i, node := range pool.nodes {
+		healthy := reachable[i] && blockNumbers[i]+pool.maxBlockLag >= best
+		if reachable[i] && !healthy {
+			log.Warnf("updater - Node %s is at block %v, %v blocks behind.", node.Name, blockNumbers[i], best-blockNumbers[i])
+		}
+		pool.healthy[i] = healthy
+		rpcNodeHealthy.WithLabelValues(node.Name).Set(boolToFloat(healthy))
+	}
+	if pool.healthy[pool.active] && !pool.preferPrimary {
+		return
+	}
+	for i := range pool.nodes {
+		if !pool.healthy[i] {
+			continue
+		}
+		if i == pool.active {
+			return
+		}
+		if pool.healthy[pool.active] {
+			log.Infof("updater - Return from node %s to %s.", pool.nodes[pool.active].Name, pool.nodes[i].Name)
+		} else {
+			log.Infof("updater - Switch from unhealthy node %s to %s.", pool.nodes[pool.active].Name, pool.nodes[i].Name)
+			rpcNodeFailovers.Inc()
+		}
+		pool.setActive(i)
+		return
+	}
+	log.Error("updater - No healthy node.")
+}
+
+// SendTransaction sends the signed transaction @tx to the active node. If the node fails, the same signed
+// transaction is rebroadcast to the other nodes, starting with the healthy ones. As all nodes receive the
+// same nonce and hash, the transaction is mined at most once. The first node accepting @tx becomes active.
+func (pool *NodePool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	pool.mu.Lock()
+	order := make([]int, 0, len(pool.nodes))
+	order = append(order, pool.active)
+	for _, healthy := range []bool{true, false} {
+		for i := range pool.nodes {
+			if i != pool.active && pool.healthy[i] == healthy {
+				order = append(order, i)
+			}
+		}
+	}
+	pool.mu.Unlock()
+
+	var err error
+	for _, i := range order {
+		node := pool.nodes[i]
+		callCtx, cancel := context.WithTimeout(ctx, pool.timeout)
+		err = node.Client.SendTransaction(callCtx, tx)
+		cancel()
+		if err != nil && strings.Contains(strings.ToLower(err.Error()), "already known") {
+			// The node received the transaction before, for instance by an earlier broadcast.
+			err = nil
+		}
+		if err == nil {
+			pool.mu.Lock()
+			if i != pool.active {
+				log.Warnf("updater - Sent transaction 0x%x through node %s after node %s failed.", tx.Hash(), node.Name, pool.nodes[pool.active].Name)
+				rpcNodeFailovers.Inc()
+				pool.setActive(i)
+			}
+			pool.mu.Unlock()
+			return nil
+		}
+		if !isNodeError(err) {
+			// The transaction itself was rejected, which another node would do as well.
+			return err
+		}
+		log.Warnf("updater - Send transaction 0x%x through node %s: %v.", tx.Hash(), node.Name, err)
+		pool.mu.Lock()
+		pool.healthy[i] = false
+		rpcNodeHealthy.WithLabelValues(node.Name).Set(0)
+		pool.mu.Unlock()
+	}
+	return err
+}
+
+// reportError deactivates the active node if @err is an error of the node, such that the next attempt
+// goes through the next healthy node.
+func (pool *NodePool) reportError(err error) {
+	if err == nil || !isNodeError(err) {
+		return
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.healthy[pool.active] = false
+	rpcNodeHealthy.WithLabelValues(pool.nodes[pool.active].Name).Set(0)
+	for i := range pool.nodes {
+		if pool.healthy[i] {
+			log.Warnf("updater - Switch from node %s to %s after error: %v.", pool.nodes[pool.active].Name, pool.nodes[i].Name, err)
+			rpcNodeFailovers.Inc()
+			pool.setActive(i)
+			return
+		}
+	}
+}
+
+// setActive activates the node with index @i. The caller must hold the lock.
+func (pool *NodePool) setActive(i int) {
+	pool.active = i
+	for j, node := range pool.nodes {
+		rpcNodeActive.WithLabelValues(node.Name).Set(boolToFloat(i == j))
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
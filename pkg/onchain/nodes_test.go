@@ -0,0 +1,161 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// fakeNode is a JSON-RPC node that answers eth_blockNumber, nonce, gas price and receipt queries, returns
+// callResult for every eth_call and records raw transactions.
+type fakeNode struct {
+	blockNumber  string
+	nonce        string
+	pendingNonce string
+	gasPrice     string
+	receipts     map[string]*types.Receipt
+	callResult   string
+	down         bool
+	// delay holds back every response.
+	delay    time.Duration
+	received []string
+}
+
+func (node *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if node.down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	time.Sleep(node.delay)
+	var request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	param := func(i int) string {
+		var p string
+		json.Unmarshal(request.Params[i], &p)
+		return p
+	}
+	var result interface{}
+	switch request.Method {
+	case "eth_blockNumber":
+		result = node.blockNumber
+	case "eth_getTransactionCount":
+		result = node.nonce
+		if param(1) == "pending" {
+			result = node.pendingNonce
+		}
+	case "eth_gasPrice":
+		result = node.gasPrice
+	case "eth_getTransactionReceipt":
+		if receipt, ok := node.receipts[param(0)]; ok {
+			result = receipt
+		}
+	case "eth_call":
+		result = node.callResult
+	case "eth_sendRawTransaction":
+		node.received = append(node.received, param(0))
+		result = "0x0000000000000000000000000000000000000000000000000000000000000000"
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": result})
+}
+
+func newTestNodePool(t *testing.T, fakes ...*fakeNode) *NodePool {
+	var nodes []Node
+	for i, fake := range fakes {
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+		client, err := ethclient.Dial(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contract, err := diaOracleV2MultiupdateService.NewDiaOracleV2MultiupdateService(common.HexToAddress("0x1"), client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, Node{Name: []string{"primary", "backup1", "backup2"}[i], Client: client, Contract: contract})
+	}
+	pool, err := NewNodePool(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestNodePoolSendTransactionFailover(t *testing.T) {
+	primary := &fakeNode{blockNumber: "0x64", down: true}
+	backup := &fakeNode{blockNumber: "0x64"}
+	pool := newTestNodePool(t, primary, backup)
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 7})
+	if err := pool.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.received) != 1 {
+		t.Fatalf("backup received %v transactions, want 1", len(backup.received))
+	}
+	if pool.Active().Name != "backup1" {
+		t.Errorf("got active node %s, want backup1", pool.Active().Name)
+	}
+
+	// The backup stays active, although the primary is back, until the next health check.
+	primary.down = false
+	if err := pool.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.received) != 2 || len(primary.received) != 0 {
+		t.Errorf("got %v transactions at primary and %v at backup, want 0 and 2", len(primary.received), len(backup.received))
+	}
+	if backup.received[0] != backup.received[1] {
+		t.Error("rebroadcast must send the same signed transaction")
+	}
+	// The backup is healthy, so it stays active after the health check.
+	pool.checkHealth(context.Background())
+	if pool.Active().Name != "backup1" {
+		t.Errorf("got active node %s after health check, want backup1", pool.Active().Name)
+	}
+	// With NODE_PREFER_PRIMARY, the health check returns to the primary.
+	pool.preferPrimary = true
+	pool.checkHealth(context.Background())
+	if pool.Active().Name != "primary" {
+		t.Errorf("got active node %s after health check preferring the primary, want primary", pool.Active().Name)
+	}
+}
+
+func TestNodePoolCheckHealthLag(t *testing.T) {
+	primary := &fakeNode{blockNumber: "0x64"}
+	backup := &fakeNode{blockNumber: "0x6e"}
+	pool := newTestNodePool(t, primary, backup)
+
+	pool.checkHealth(context.Background())
+	if pool.Active().Name != "backup1" {
+		t.Errorf("got active node %s, want backup1 as the primary lags 10 blocks", pool.Active().Name)
+	}
+
+	// The primary caught up, but the backup stays active as long as it is healthy.
+	primary.blockNumber = "0x6e"
+	pool.checkHealth(context.Background())
+	if pool.Active().Name != "backup1" {
+		t.Errorf("got active node %s, want backup1 as it is healthy", pool.Active().Name)
+	}
+
+	// The backup lags, so the pool fails over to the primary.
+	primary.blockNumber = "0x78"
+	pool.checkHealth(context.Background())
+	if pool.Active().Name != "primary" {
+		t.Errorf("got active node %s, want primary as backup1 lags 10 blocks", pool.Active().Name)
+	}
+}
@@ -0,0 +1,286 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// minGasBumpPercent is the minimal gas price increase nodes accept for replacing a pending transaction.
+const minGasBumpPercent = 10
+
+// inflightTx is a sent transaction that is not mined yet.
+type inflightTx struct {
+	nonce uint64
+	// tx is the latest version of the transaction. hashes contains the hashes of all versions.
+	tx     *types.Transaction
+	hashes []common.Hash
+	sentAt time.Time
+	update sentUpdate
+}
+
+// nonceManager assigns nonces to oracle updates and watches sent transactions until they are mined.
+// A transaction that is not mined within TX_STUCK_SECONDS is replaced by the same transaction at the same nonce
+// with fees increased by TX_GAS_BUMP_PERCENT, as long as they stay below MAX_GAS_PRICE_GWEI. On startup, the next nonce is the account's nonce in the
+// latest block, so that transactions left pending by a previous run are replaced as well.
+// Mined transactions are handed to the confirmationTracker.
+// All methods must be called from the goroutine running OracleUpdateExecutor.
+type nonceManager struct {
+	nodes         *NodePool
+	auth          *bind.TransactOpts
+	gas           GasStrategy
+	confirmations *confirmationTracker
+	next          uint64
+	synced        bool
+	// bumps counts rejected replacements per nonce, each of which raises the fees of the next attempt.
+	bumps          map[uint64]int
+	inflight       []*inflightTx
+	stuckAfter     time.Duration
+	gasBumpPercent float64
+	// maxGasPrice is nil if the gas price is not limited.
+	maxGasPrice *big.Int
+	now         func() time.Time
+}
+
+func newNonceManager(nodes *NodePool, auth *bind.TransactOpts, gas GasStrategy, confirmations *confirmationTracker) *nonceManager {
+	gasBumpPercent := utils.GetenvFloat("TX_GAS_BUMP_PERCENT", 12.5)
+	if gasBumpPercent < minGasBumpPercent {
+		log.Warnf("updater - TX_GAS_BUMP_PERCENT must be at least %v.", minGasBumpPercent)
+		gasBumpPercent = minGasBumpPercent
+	}
+	var maxGasPrice *big.Int
+	if maxGasPriceGwei := utils.GetenvFloat("MAX_GAS_PRICE_GWEI", 0); maxGasPriceGwei > 0 {
+		maxGasPrice = gweiToWei(maxGasPriceGwei)
+	}
+	return &nonceManager{
+		nodes:          nodes,
+		auth:           auth,
+		gas:            gas,
+		confirmations:  confirmations,
+		bumps:          make(map[uint64]int),
+		stuckAfter:     time.Duration(utils.GetenvFloat("TX_STUCK_SECONDS", 60)*1000) * time.Millisecond,
+		gasBumpPercent: gasBumpPercent,
+		maxGasPrice:    maxGasPrice,
+		now:            time.Now,
+	}
+}
+
+// sync reconciles the next nonce with the chain. Transactions with a nonce below the account's nonce in the
+// latest block are mined.
+func (m *nonceManager) sync(ctx context.Context) error {
+	client := m.nodes.Active().Client
+	callCtx, cancel := context.WithTimeout(ctx, m.nodes.timeout)
+	latest, err := client.NonceAt(callCtx, m.auth.From, nil)
+	cancel()
+	if err != nil {
+		return err
+	}
+	m.removeMined(latest)
+	if len(m.inflight) == 0 {
+		callCtx, cancel := context.WithTimeout(ctx, m.nodes.timeout)
+		pending, err := client.PendingNonceAt(callCtx, m.auth.From)
+		cancel()
+		if err == nil && pending > latest {
+			log.Warnf("updater - %v transactions of %s are pending since before the nonce was tracked. They will be replaced.", pending-latest, m.auth.From.Hex())
+		}
+		m.next = latest
+	} else if latest > m.next {
+		m.next = latest
+	}
+	m.synced = true
+	oracleNonce.Set(float64(m.next))
+	return nil
+}
+
+// nonce returns the nonce of the next transaction.
+func (m *nonceManager) nonce(ctx context.Context) (uint64, error) {
+	if !m.synced {
+		if err := m.sync(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return m.next, nil
+}
+
+// fees returns @fees raised for each rejected attempt at @nonce and limited to the maximal gas price.
+func (m *nonceManager) fees(nonce uint64, fees GasFees) (GasFees, error) {
+	for i := 0; i < m.bumps[nonce]; i++ {
+		fees = fees.bump(m.gasBumpPercent)
+	}
+	return limitFees(fees, m.maxGasPrice)
+}
+
+// sent records that @tx writing @update was sent.
+func (m *nonceManager) sent(tx *types.Transaction, update sentUpdate) {
+	m.inflight = append(m.inflight, &inflightTx{nonce: tx.Nonce(), tx: tx, hashes: []common.Hash{tx.Hash()}, sentAt: m.now(), update: update})
+	delete(m.bumps, tx.Nonce())
+	m.next = tx.Nonce() + 1
+	oracleNonce.Set(float64(m.next))
+	oraclePendingTxs.Set(float64(len(m.inflight)))
+}
+
+// failed handles the error @err of sending a transaction with @nonce.
+func (m *nonceManager) failed(nonce uint64, err error) {
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "nonce too low"):
+		// Another transaction of the account was mined at this nonce.
+		m.synced = false
+	case strings.Contains(message, "underpriced"):
+		// A pending transaction at this nonce has a higher gas price.
+		m.bumps[nonce]++
+	}
+}
+
+// removeMined removes all transactions with a nonce below @latest.
+func (m *nonceManager) removeMined(latest uint64) {
+	var inflight []*inflightTx
+	for _, itx := range m.inflight {
+		if itx.nonce >= latest {
+			inflight = append(inflight, itx)
+		}
+	}
+	m.inflight = inflight
+	oraclePendingTxs.Set(float64(len(m.inflight)))
+}
+
+// watch removes mined transactions and replaces the oldest transaction if it is stuck.
+func (m *nonceManager) watch(ctx context.Context) {
+	for _, itx := range m.confirmations.check(ctx) {
+		m.resend(itx)
+	}
+	if len(m.inflight) == 0 {
+		return
+	}
+	client := m.nodes.Active().Client
+	for len(m.inflight) > 0 {
+		itx := m.inflight[0]
+		receipt := m.receipt(ctx, itx)
+		if receipt == nil {
+			break
+		}
+		log.Infof("updater - Transaction 0x%x with nonce %v mined in block %v with status %v.", receipt.TxHash, itx.nonce, receipt.BlockNumber, receipt.Status)
+		m.confirmations.add(itx, receipt)
+		m.removeMined(itx.nonce + 1)
+	}
+	if len(m.inflight) == 0 {
+		return
+	}
+
+	// The oldest transaction may also have been mined in a version sent by another process.
+	callCtx, cancel := context.WithTimeout(ctx, m.nodes.timeout)
+	latest, err := client.NonceAt(callCtx, m.auth.From, nil)
+	cancel()
+	if err != nil {
+		log.Warnf("updater - NonceAt: %v.", err)
+		return
+	}
+	m.removeMined(latest)
+	if len(m.inflight) == 0 || m.now().Sub(m.inflight[0].sentAt) < m.stuckAfter {
+		return
+	}
+	if err := m.replace(ctx, m.inflight[0]); err != nil {
+		log.Errorf("updater - Replace transaction with nonce %v: %v.", m.inflight[0].nonce, err)
+	}
+}
+
+// resend watches @itx again after it was reorged out of the chain.
+func (m *nonceManager) resend(itx *inflightTx) {
+	itx.sentAt = m.now()
+	i := sort.Search(len(m.inflight), func(i int) bool { return m.inflight[i].nonce >= itx.nonce })
+	m.inflight = append(m.inflight[:i], append([]*inflightTx{itx}, m.inflight[i:]...)...)
+	oraclePendingTxs.Set(float64(len(m.inflight)))
+}
+
+// receipt returns the receipt of any version of @itx or nil if none was mined.
+func (m *nonceManager) receipt(ctx context.Context, itx *inflightTx) *types.Receipt {
+	client := m.nodes.Active().Client
+	for _, hash := range itx.hashes {
+		callCtx, cancel := context.WithTimeout(ctx, m.nodes.timeout)
+		receipt, err := client.TransactionReceipt(callCtx, hash)
+		cancel()
+		if err == nil {
+			return receipt
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			log.Warnf("updater - TransactionReceipt of 0x%x: %v.", hash, err)
+		}
+	}
+	return nil
+}
+
+// replace sends the stuck transaction @itx again at the same nonce with bumped fees.
+func (m *nonceManager) replace(ctx context.Context, itx *inflightTx) error {
+	fees := feesOf(itx.tx).bump(m.gasBumpPercent)
+	callCtx, cancel := context.WithTimeout(ctx, m.nodes.timeout)
+	current, err := m.gas.Fees(callCtx, m.nodes.Active().Client)
+	cancel()
+	if err == nil && current.Dynamic() == fees.Dynamic() {
+		// Pay the current fees if they rose above the bumped ones.
+		fees.GasPrice = maxGas(fees.GasPrice, current.GasPrice)
+		fees.GasTipCap = maxGas(fees.GasTipCap, current.GasTipCap)
+		fees.GasFeeCap = maxGas(fees.GasFeeCap, current.GasFeeCap)
+		fees.BaseFee = current.BaseFee
+	}
+	fees, err = limitFees(fees, m.maxGasPrice)
+	if err != nil {
+		return err
+	}
+	if required := feesOf(itx.tx).bump(minGasBumpPercent); fees.Max().Cmp(required.Max()) < 0 {
+		// The fee cap was lowered so much that nodes would reject the replacement.
+		return fmt.Errorf("%w: replacement needs %v", errGasPriceTooHigh, required.Max())
+	}
+	var unsigned *types.Transaction
+	if fees.Dynamic() {
+		unsigned = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   itx.tx.ChainId(),
+			Nonce:     itx.nonce,
+			GasTipCap: fees.GasTipCap,
+			GasFeeCap: fees.GasFeeCap,
+			Gas:       itx.tx.Gas(),
+			To:        itx.tx.To(),
+			Value:     itx.tx.Value(),
+			Data:      itx.tx.Data(),
+		})
+	} else {
+		unsigned = types.NewTx(&types.LegacyTx{
+			Nonce:    itx.nonce,
+			GasPrice: fees.GasPrice,
+			Gas:      itx.tx.Gas(),
+			To:       itx.tx.To(),
+			Value:    itx.tx.Value(),
+			Data:     itx.tx.Data(),
+		})
+	}
+	replacement, err := m.auth.Signer(m.auth.From, unsigned)
+	if err != nil {
+		return err
+	}
+	if err := m.nodes.SendTransaction(ctx, replacement); err != nil {
+		return err
+	}
+	log.Warnf("updater - Replaced transaction 0x%x with nonce %v, pending for %v, by 0x%x with gas price %v.", itx.tx.Hash(), itx.nonce, m.now().Sub(itx.sentAt), replacement.Hash(), fees.Max())
+	itx.tx = replacement
+	itx.hashes = append(itx.hashes, replacement.Hash())
+	itx.sentAt = m.now()
+	oracleReplacedTxs.Inc()
+	return nil
+}
+
+// maxGas returns the larger of @a and @b, where nil is smallest.
+func maxGas(a, b *big.Int) *big.Int {
+	if a == nil || (b != nil && b.Cmp(a) > 0) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,112 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newTestNonceManager(t *testing.T, node *fakeNode) *nonceManager {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := newTestNodePool(t, node)
+	return newNonceManager(pool, auth, legacyGasStrategy{multiplier: 1}, &confirmationTracker{nodes: pool, state: newPublicationState(nil), confirmations: 1})
+}
+
+func TestNonceManagerSyncAfterRestart(t *testing.T) {
+	node := &fakeNode{blockNumber: "0x64", nonce: "0x5", pendingNonce: "0x7"}
+	m := newTestNonceManager(t, node)
+
+	// Transactions at nonces 5 and 6 are pending from a previous run and must be replaced.
+	nonce, err := m.nonce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 5 {
+		t.Errorf("got nonce %v, want 5", nonce)
+	}
+	m.failed(5, errors.New("replacement transaction underpriced"))
+	fees, err := m.fees(5, GasFees{GasPrice: big.NewInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasPrice.Cmp(big.NewInt(113)) != 0 {
+		t.Errorf("got gas price %v after underpriced replacement, want 113", fees.GasPrice)
+	}
+}
+
+func TestNonceManagerReplacesStuckTx(t *testing.T) {
+	node := &fakeNode{blockNumber: "0x64", nonce: "0x5", pendingNonce: "0x5", gasPrice: "0x64", receipts: make(map[string]*types.Receipt)}
+	m := newTestNonceManager(t, node)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	m.stuckAfter = time.Minute
+
+	to := common.HexToAddress("0x1")
+	tx, err := m.auth.Signer(m.auth.From, types.NewTx(&types.LegacyTx{Nonce: 5, To: &to, Gas: 100000, GasPrice: big.NewInt(100)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.sent(tx, sentUpdate{})
+	if m.next != 6 {
+		t.Fatalf("got next nonce %v, want 6", m.next)
+	}
+
+	now = now.Add(30 * time.Second)
+	m.watch(context.Background())
+	if len(node.received) != 0 {
+		t.Fatal("transaction must not be replaced before it is stuck")
+	}
+
+	now = now.Add(time.Minute)
+	m.watch(context.Background())
+	if len(node.received) != 1 {
+		t.Fatalf("got %v replacements, want 1", len(node.received))
+	}
+	replacement := new(types.Transaction)
+	if err := replacement.UnmarshalBinary(hexutil.MustDecode(node.received[0])); err != nil {
+		t.Fatal(err)
+	}
+	if replacement.Nonce() != 5 || replacement.GasPrice().Cmp(big.NewInt(113)) < 0 {
+		t.Errorf("got nonce %v and gas price %v, want nonce 5 and gas price at least 113", replacement.Nonce(), replacement.GasPrice())
+	}
+	if string(replacement.Data()) != string(tx.Data()) || *replacement.To() != to {
+		t.Error("replacement must carry the same call")
+	}
+
+	// The replacement is mined.
+	node.receipts[replacement.Hash().Hex()] = &types.Receipt{TxHash: replacement.Hash(), Status: 1, BlockNumber: big.NewInt(101), Logs: []*types.Log{}}
+	m.watch(context.Background())
+	if len(m.inflight) != 0 {
+		t.Errorf("got %v pending transactions, want 0", len(m.inflight))
+	}
+}
+
+func TestUpdateOracleMultiValuesTimeout(t *testing.T) {
+	node := &fakeNode{blockNumber: "0x64", nonce: "0x5", gasPrice: "0x3b9aca00", delay: 500 * time.Millisecond}
+	m := newTestNonceManager(t, node)
+	m.nodes.timeout = 20 * time.Millisecond
+
+	start := time.Now()
+	_, err := updateOracleMultiValues(context.Background(), m.nodes, m.auth, m.gas, m, []string{"BTC/USD"}, []*big.Int{big.NewInt(1)}, 100)
+	if err == nil {
+		t.Fatal("update through a hanging node must fail")
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("update returned after %v, want about the node timeout", elapsed)
+	}
+}
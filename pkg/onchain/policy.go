@@ -0,0 +1,189 @@
+package onchain
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// PublicationPolicy determines whether a new value of a feed is written to the oracle.
+// A value is published if it deviates by more than DeviationPermille from the last published value,
+// or if Heartbeat has passed since the last publication. Zero disables the respective rule.
+// If both rules are disabled, every value is published.
+type PublicationPolicy struct {
+	DeviationPermille float64
+	Heartbeat         time.Duration
+}
+
+// PublicationPolicyFromEnv returns the policy of the feed with @key of the asset with @symbol. It is given by
+// DEVIATION_PERMILLE_<KEY> and HEARTBEAT_SECONDS_<KEY>, where <KEY> is @key in envKey form, e.g. BTC_USD_100000
+// for BTC/USD@100000. They default to DEVIATION_PERMILLE_<SYMBOL> and HEARTBEAT_SECONDS_<SYMBOL>, which default to
+// DEVIATION_PERMILLE and HEARTBEAT_SECONDS.
+func PublicationPolicyFromEnv(key string, symbol string) PublicationPolicy {
+	deviationPermille := utils.GetenvFloat("DEVIATION_PERMILLE", 0)
+	deviationPermille = utils.GetenvFloat("DEVIATION_PERMILLE_"+strings.ToUpper(symbol), deviationPermille)
+	heartbeatSeconds := utils.GetenvFloat("HEARTBEAT_SECONDS", 0)
+	heartbeatSeconds = utils.GetenvFloat("HEARTBEAT_SECONDS_"+strings.ToUpper(symbol), heartbeatSeconds)
+	return PublicationPolicy{
+		DeviationPermille: utils.GetenvFloat("DEVIATION_PERMILLE_"+envKey(key), deviationPermille),
+		Heartbeat:         time.Duration(utils.GetenvFloat("HEARTBEAT_SECONDS_"+envKey(key), heartbeatSeconds)*1000) * time.Millisecond,
+	}
+}
+
+// envKey returns the oracle @key as part of the name of an environment variable. It is upper case, and characters
+// other than letters and digits are replaced by underscores, i.e. BTC/USD@100000 becomes BTC_USD_100000.
+func envKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(key))
+}
+
+// publishedValue is the latest value of a feed in the oracle.
+type publishedValue struct {
+	Value     float64
+	Timestamp time.Time
+}
+
+// shouldPublish returns true if @value at @now qualifies for publication given the @last published value.
+func (policy PublicationPolicy) shouldPublish(last publishedValue, value float64, now time.Time) bool {
+	if policy.DeviationPermille == 0 && policy.Heartbeat == 0 {
+		return true
+	}
+	if last.Timestamp.IsZero() || last.Value == 0 {
+		return true
+	}
+	if policy.DeviationPermille > 0 && math.Abs(value-last.Value)/math.Abs(last.Value)*1000 > policy.DeviationPermille {
+		return true
+	}
+	if policy.Heartbeat > 0 && now.Sub(last.Timestamp) >= policy.Heartbeat {
+		return true
+	}
+	return false
+}
+
+// publicationState keeps the latest published value, the policy and the decimals of each feed.
+type publicationState struct {
+	nodes         *NodePool
+	published     map[string]publishedValue
+	policies      map[string]PublicationPolicy
+	decimalsByKey map[string]int
+}
+
+func newPublicationState(nodes *NodePool) *publicationState {
+	return &publicationState{
+		nodes:         nodes,
+		published:     make(map[string]publishedValue),
+		policies:      make(map[string]PublicationPolicy),
+		decimalsByKey: make(map[string]int),
+	}
+}
+
+// policy returns the policy for @key of the asset with @symbol.
+func (state *publicationState) policy(key string, symbol string) PublicationPolicy {
+	policy, ok := state.policies[key]
+	if !ok {
+		policy = PublicationPolicyFromEnv(key, symbol)
+		state.policies[key] = policy
+	}
+	return policy
+}
+
+// decimals returns the decimals of @key of the asset with @symbol.
+func (state *publicationState) decimals(key string, symbol string) int {
+	decimals, ok := state.decimalsByKey[key]
+	if !ok {
+		decimals = DecimalsFromEnv(key, symbol)
+		state.decimalsByKey[key] = decimals
+	}
+	return decimals
+}
+
+// load reads the latest published values of @feeds from the oracle. Each read is bounded by the node timeout.
+// Values that cannot be read are read again when the first value of their feed arrives.
+func (state *publicationState) load(ctx context.Context, feeds []models.Feed) {
+	for _, feed := range feeds {
+		if _, ok := state.published[feed.Key()]; ok {
+			continue
+		}
+		state.read(ctx, feed.Key(), feed.Asset.Symbol)
+	}
+}
+
+// read reads the latest published value of @key of the asset with @symbol from the oracle and records it.
+// The value is only recorded if the read succeeds.
+func (state *publicationState) read(ctx context.Context, key string, symbol string) (last publishedValue, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, state.nodes.timeout)
+	defer cancel()
+	value, timestamp, err := state.nodes.Active().Contract.GetValue(&bind.CallOpts{Context: ctx}, key)
+	if err != nil {
+		log.Warnf("updater - GetValue of %s: %v.", key, err)
+		return last, false
+	}
+	if timestamp.Sign() > 0 {
+		last.Value = decodeValue(value, state.decimals(key, symbol))
+		last.Timestamp = time.Unix(timestamp.Int64(), 0)
+		log.Infof("updater - Last published value of %s: %v at %v.", key, last.Value, last.Timestamp)
+	}
+	state.published[key] = last
+	return last, true
+}
+
+// lastPublished returns the latest published value for @key of the asset with @symbol. The value of a key
+// that was neither loaded nor published by this feeder so far is read from the oracle.
+func (state *publicationState) lastPublished(ctx context.Context, key string, symbol string) publishedValue {
+	if last, ok := state.published[key]; ok || state.nodes == nil {
+		return last
+	}
+	last, _ := state.read(ctx, key, symbol)
+	return last
+}
+
+// shouldPublish returns true if @value of the asset with @symbol qualifies for publication under @key at @now.
+func (state *publicationState) shouldPublish(ctx context.Context, key string, symbol string, value float64, now time.Time) bool {
+	return state.policy(key, symbol).shouldPublish(state.lastPublished(ctx, key, symbol), value, now)
+}
+
+// forget drops the latest published value of @keys, such that it is read from the oracle again.
+func (state *publicationState) forget(keys ...string) {
+	for _, key := range keys {
+		delete(state.published, key)
+	}
+}
+
+// priority returns the urgency of publishing @value of the asset with @symbol under @key at @now. It is the larger
+// of the deviation from the last published value in units of DeviationPermille and of the age of the last
+// publication in units of Heartbeat, where disabled rules count in units of 1 permille and 1 hour.
+// Keys without a known published value have the highest priority.
+func (state *publicationState) priority(key string, symbol string, value float64, now time.Time) float64 {
+	last, ok := state.published[key]
+	if !ok || last.Timestamp.IsZero() || last.Value == 0 {
+		return math.Inf(1)
+	}
+	policy := state.policy(key, symbol)
+	deviationUnit := policy.DeviationPermille
+	if deviationUnit == 0 {
+		deviationUnit = 1
+	}
+	heartbeatUnit := policy.Heartbeat
+	if heartbeatUnit == 0 {
+		heartbeatUnit = time.Hour
+	}
+	deviation := math.Abs(value-last.Value) / math.Abs(last.Value) * 1000 / deviationUnit
+	age := float64(now.Sub(last.Timestamp)) / float64(heartbeatUnit)
+	return math.Max(deviation, age)
+}
+
+// setPublished records the publication of @values under @keys at @timestamp.
+func (state *publicationState) setPublished(keys []string, values []float64, timestamp time.Time) {
+	for i, key := range keys {
+		state.published[key] = publishedValue{Value: values[i], Timestamp: timestamp}
+	}
+}
@@ -0,0 +1,109 @@
+package onchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/models"
+	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestPublicationPolicyShouldPublish(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	last := publishedValue{Value: 100, Timestamp: t0}
+	policy := PublicationPolicy{DeviationPermille: 5, Heartbeat: time.Hour}
+
+	cases := []struct {
+		name   string
+		policy PublicationPolicy
+		last   publishedValue
+		value  float64
+		now    time.Time
+		want   bool
+	}{
+		{"small deviation", policy, last, 100.4, t0.Add(time.Minute), false},
+		{"deviation above threshold", policy, last, 100.6, t0.Add(time.Minute), true},
+		{"negative deviation above threshold", policy, last, 99.4, t0.Add(time.Minute), true},
+		{"heartbeat", policy, last, 100, t0.Add(time.Hour), true},
+		{"never published", policy, publishedValue{}, 100, t0, true},
+		{"deviation only", PublicationPolicy{DeviationPermille: 5}, last, 100, t0.Add(24 * time.Hour), false},
+		{"no rules", PublicationPolicy{}, last, 100, t0, true},
+	}
+	for _, c := range cases {
+		if got := c.policy.shouldPublish(c.last, c.value, c.now); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPublicationPolicyFromEnv(t *testing.T) {
+	t.Setenv("DEVIATION_PERMILLE", "5")
+	t.Setenv("HEARTBEAT_SECONDS", "3600")
+	t.Setenv("DEVIATION_PERMILLE_BTC", "2")
+	t.Setenv("DEVIATION_PERMILLE_BTC_USD_100000", "20")
+	t.Setenv("HEARTBEAT_SECONDS_BTC_USD_100000", "600")
+
+	cases := []struct {
+		key    string
+		symbol string
+		want   PublicationPolicy
+	}{
+		{"BTC/USD", "BTC", PublicationPolicy{DeviationPermille: 2, Heartbeat: time.Hour}},
+		{"BTC/USD@100000", "BTC", PublicationPolicy{DeviationPermille: 20, Heartbeat: 10 * time.Minute}},
+		{"BTC/USD@1000", "BTC", PublicationPolicy{DeviationPermille: 2, Heartbeat: time.Hour}},
+		{"ETH/USD", "ETH", PublicationPolicy{DeviationPermille: 5, Heartbeat: time.Hour}},
+	}
+	for _, c := range cases {
+		if got := PublicationPolicyFromEnv(c.key, c.symbol); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.key, got, c.want)
+		}
+	}
+}
+
+func TestPublicationStateSetPublished(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	state := newPublicationState(nil)
+	state.policies["BTC/USD"] = PublicationPolicy{DeviationPermille: 10}
+
+	if !state.shouldPublish(context.Background(), "BTC/USD", "BTC", 100, t0) {
+		t.Fatal("first value must be published")
+	}
+	state.setPublished([]string{"BTC/USD"}, []float64{100}, t0)
+	if state.shouldPublish(context.Background(), "BTC/USD", "BTC", 100.5, t0.Add(time.Minute)) {
+		t.Error("value within deviation must not be published")
+	}
+	// Size feeds keep their own published values.
+	if !state.shouldPublish(context.Background(), "BTC/USD@100000", "BTC", 100.5, t0.Add(time.Minute)) {
+		t.Error("first value of size feed must be published")
+	}
+}
+
+func TestPublicationStateLoad(t *testing.T) {
+	parsed, err := diaOracleV2MultiupdateService.DiaOracleV2MultiupdateServiceMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	getValue, err := parsed.Methods["getValue"].Outputs.Pack(big.NewInt(10000000000), big.NewInt(1700000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &fakeNode{blockNumber: "0x64", callResult: hexutil.Encode(getValue), down: true}
+	state := newPublicationState(newTestNodePool(t, node))
+	state.decimalsByKey["BTC/USD"] = 8
+	feeds := []models.Feed{{Asset: models.Asset{Symbol: "BTC"}}}
+
+	state.load(context.Background(), feeds)
+	if len(state.published) != 0 {
+		t.Fatal("failed reads must not be recorded")
+	}
+
+	node.down = false
+	state.load(context.Background(), feeds)
+	want := publishedValue{Value: 100, Timestamp: time.Unix(1700000000, 0)}
+	if got := state.published["BTC/USD"]; got != want {
+		t.Errorf("got published value %v, want %v", got, want)
+	}
+}
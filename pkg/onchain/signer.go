@@ -0,0 +1,215 @@
+package onchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Names of the signers selectable by SIGNER.
+const (
+	signerPrivateKey = "privatekey"
+	signerKeystore   = "keystore"
+	signerClef       = "clef"
+	signerRemote     = "remote"
+)
+
+// NewTransactorFromEnv returns transact options for @chainId that sign with the signer selected by SIGNER:
+//   - privatekey (default): the hex key in PRIVATE_KEY.
+//   - keystore: the go-ethereum encrypted keystore file KEYSTORE_FILE, decrypted with the passphrase in
+//     KEYSTORE_PASSWORD_FILE.
+//   - clef: the Clef signer at CLEF_ENDPOINT, an IPC path or HTTP URL. SIGNER_ADDRESS selects the account
+//     if Clef manages several.
+//   - remote: the remote signer HTTP API at REMOTE_SIGNER_URL for the account SIGNER_ADDRESS, see remoteSigner.
+func NewTransactorFromEnv(chainId *big.Int) (*bind.TransactOpts, error) {
+	switch name := utils.Getenv("SIGNER", signerPrivateKey); name {
+	case signerPrivateKey:
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(utils.Getenv("PRIVATE_KEY", ""), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("load private key: %w", err)
+		}
+		return bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	case signerKeystore:
+		return newKeystoreTransactor(utils.Getenv("KEYSTORE_FILE", ""), utils.Getenv("KEYSTORE_PASSWORD_FILE", ""), chainId)
+	case signerClef:
+		return newClefTransactor(utils.Getenv("CLEF_ENDPOINT", ""), utils.Getenv("SIGNER_ADDRESS", ""), chainId)
+	case signerRemote:
+		address := utils.Getenv("SIGNER_ADDRESS", "")
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("SIGNER_ADDRESS %q is not an address", address)
+		}
+		var token string
+		if tokenFile := utils.Getenv("REMOTE_SIGNER_TOKEN_FILE", ""); tokenFile != "" {
+			contents, err := os.ReadFile(tokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("read REMOTE_SIGNER_TOKEN_FILE: %w", err)
+			}
+			token = strings.TrimSpace(string(contents))
+		}
+		signer := &remoteSigner{
+			url:     utils.Getenv("REMOTE_SIGNER_URL", ""),
+			token:   token,
+			client:  &http.Client{Timeout: time.Duration(utils.GetenvFloat("REMOTE_SIGNER_TIMEOUT_SECONDS", 10)*1000) * time.Millisecond},
+			chainId: chainId,
+		}
+		return signer.transactor(common.HexToAddress(address)), nil
+	default:
+		return nil, fmt.Errorf("unknown SIGNER %s", name)
+	}
+}
+
+// newKeystoreTransactor decrypts the keystore file at @keyFile with the passphrase in @passwordFile.
+func newKeystoreTransactor(keyFile string, passwordFile string, chainId *big.Int) (*bind.TransactOpts, error) {
+	key, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("open KEYSTORE_FILE: %w", err)
+	}
+	defer key.Close()
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return nil, fmt.Errorf("read KEYSTORE_PASSWORD_FILE: %w", err)
+	}
+	// Editors and echo append a newline that is not part of the passphrase.
+	return bind.NewTransactorWithChainID(key, strings.TrimRight(string(password), "\r\n"), chainId)
+}
+
+// newClefTransactor signs through the Clef signer at @endpoint with the account @address, or with the only
+// account of Clef if @address is empty.
+func newClefTransactor(endpoint string, address string, chainId *big.Int) (*bind.TransactOpts, error) {
+	clef, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("connect to Clef: %w", err)
+	}
+	var account accounts.Account
+	switch {
+	case address != "":
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("SIGNER_ADDRESS %q is not an address", address)
+		}
+		account = accounts.Account{Address: common.HexToAddress(address)}
+		if !clef.Contains(account) {
+			return nil, fmt.Errorf("clef does not manage %s", account.Address.Hex())
+		}
+	case len(clef.Accounts()) == 1:
+		account = clef.Accounts()[0]
+	default:
+		return nil, fmt.Errorf("clef manages %v accounts, set SIGNER_ADDRESS", len(clef.Accounts()))
+	}
+	return &bind.TransactOpts{
+		From: account.Address,
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != account.Address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return clef.SignTx(account, tx, chainId)
+		},
+		Context: context.Background(),
+	}, nil
+}
+
+// remoteSigner signs transactions through a remote signer HTTP API. A request is a POST to url with the JSON body
+//
+//	{"address": "0x<signer>", "chainId": "0x<chain id>", "transaction": "0x<unsigned transaction>"}
+//
+// where the transaction is in its binary encoding. With a token, the request carries the header
+// "Authorization: Bearer <token>". The response is the JSON body
+//
+//	{"signedTransaction": "0x<signed transaction>"}
+//
+// The signed transaction must be the requested transaction, signed by the requested address.
+type remoteSigner struct {
+	url     string
+	token   string
+	client  *http.Client
+	chainId *big.Int
+}
+
+type remoteSignRequest struct {
+	Address     common.Address `json:"address"`
+	ChainId     *hexutil.Big   `json:"chainId"`
+	Transaction hexutil.Bytes  `json:"transaction"`
+}
+
+type remoteSignResponse struct {
+	SignedTransaction hexutil.Bytes `json:"signedTransaction"`
+}
+
+// transactor returns transact options signing for @from through @signer.
+func (signer *remoteSigner) transactor(from common.Address) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.sign(address, tx)
+		},
+		Context: context.Background(),
+	}
+}
+
+// sign returns @tx signed by @from.
+func (signer *remoteSigner) sign(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	unsigned, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(remoteSignRequest{Address: from, ChainId: (*hexutil.Big)(signer.chainId), Transaction: unsigned})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, signer.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if signer.token != "" {
+		request.Header.Set("Authorization", "Bearer "+signer.token)
+	}
+	response, err := signer.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer returned %s", response.Status)
+	}
+	var result remoteSignResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(result.SignedTransaction); err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	// Never broadcast a transaction other than the requested one.
+	txSigner := types.LatestSignerForChainID(signer.chainId)
+	if txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, errors.New("remote signer returned another transaction")
+	}
+	sender, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	if sender != from {
+		return nil, fmt.Errorf("remote signer signed with %s instead of %s", sender.Hex(), from.Hex())
+	}
+	return signed, nil
+}
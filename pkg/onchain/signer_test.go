@@ -0,0 +1,119 @@
+package onchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// newRemoteSignerStub returns a remote signer API that signs with a new key after applying @tamper to the
+// requested transaction.
+func newRemoteSignerStub(t *testing.T, token string, tamper func(*types.LegacyTx)) (*httptest.Server, common.Address) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var request remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(request.Transaction); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		legacy := &types.LegacyTx{Nonce: tx.Nonce(), GasPrice: tx.GasPrice(), Gas: tx.Gas(), To: tx.To(), Value: tx.Value(), Data: tx.Data()}
+		if tamper != nil {
+			tamper(legacy)
+		}
+		signed, err := types.SignNewTx(key, types.LatestSignerForChainID(request.ChainId.ToInt()), legacy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		raw, _ := signed.MarshalBinary()
+		json.NewEncoder(w).Encode(remoteSignResponse{SignedTransaction: raw})
+	}))
+	t.Cleanup(server.Close)
+	return server, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestRemoteSigner(t *testing.T) {
+	server, address := newRemoteSignerStub(t, "secret", nil)
+	signer := &remoteSigner{url: server.URL, token: "secret", client: server.Client(), chainId: big.NewInt(10640)}
+	auth := signer.transactor(address)
+
+	to := common.HexToAddress("0x1")
+	tx := types.NewTx(&types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(100), Gas: 100000, To: &to, Data: []byte{1, 2}})
+	signed, err := auth.Signer(address, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(10640)), signed)
+	if err != nil || sender != address {
+		t.Errorf("got sender %s (%v), want %s", sender.Hex(), err, address.Hex())
+	}
+
+	signer.token = "wrong"
+	if _, err := auth.Signer(address, tx); err == nil {
+		t.Error("rejected request must fail")
+	}
+	if _, err := auth.Signer(common.HexToAddress("0x2"), tx); err == nil {
+		t.Error("signing for another address must fail")
+	}
+}
+
+func TestRemoteSignerRejectsOtherTransaction(t *testing.T) {
+	server, address := newRemoteSignerStub(t, "", func(tx *types.LegacyTx) { tx.GasPrice = big.NewInt(1000000) })
+	signer := &remoteSigner{url: server.URL, client: server.Client(), chainId: big.NewInt(10640)}
+
+	to := common.HexToAddress("0x1")
+	tx := types.NewTx(&types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(100), Gas: 100000, To: &to})
+	if _, err := signer.sign(address, tx); err == nil {
+		t.Error("transaction changed by the remote signer must be rejected")
+	}
+}
+
+func TestKeystoreTransactor(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &keystore.Key{Id: uuid.New(), Address: crypto.PubkeyToAddress(privateKey.PublicKey), PrivateKey: privateKey}
+	encrypted, err := keystore.EncryptKey(key, "passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyFile, passwordFile := filepath.Join(dir, "key.json"), filepath.Join(dir, "password")
+	if err := os.WriteFile(keyFile, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(passwordFile, []byte("passphrase\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := newKeystoreTransactor(keyFile, passwordFile, big.NewInt(10640))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.From != key.Address {
+		t.Errorf("got address %s, want %s", auth.From.Hex(), key.Address.Hex())
+	}
+}
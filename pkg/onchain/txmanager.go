@@ -0,0 +1,236 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// txState is the state of the transaction pipeline as exported by feeder_oracle_update_state.
+type txState int
+
+const (
+	txStateOK txState = iota
+	txStateRetrying
+	txStateFailing
+)
+
+// txErrorClass tells whether a failed update is worth retrying right away.
+type txErrorClass string
+
+const (
+	// Retryable errors are expected to vanish on their own, such as RPC timeouts or a nonce race.
+	txErrorRetryable txErrorClass = "retryable"
+	// Fatal errors need an operator, such as an unfunded account or a key that is not the oracle updater.
+	// Updates are still retried, but at the maximal backoff.
+	txErrorFatal txErrorClass = "fatal"
+)
+
+var (
+	retryableTxErrors = []string{
+		errGasPriceTooHigh.Error(),
+		"nonce too low",
+		"replacement transaction underpriced",
+		"transaction underpriced",
+		"already known",
+		"timeout",
+		"deadline exceeded",
+		"connection refused",
+		"connection reset",
+		"eof",
+		"too many requests",
+		"429",
+		"503",
+	}
+	fatalTxErrors = []string{
+		"insufficient funds",
+		"not the oracle updater",
+		"execution reverted",
+		"invalid sender",
+	}
+)
+
+// classifyTxError returns the class of @err. Unknown errors are retryable.
+func classifyTxError(err error) txErrorClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return txErrorRetryable
+	}
+	message := strings.ToLower(err.Error())
+	for _, fatal := range fatalTxErrors {
+		if strings.Contains(message, fatal) {
+			return txErrorFatal
+		}
+	}
+	for _, retryable := range retryableTxErrors {
+		if strings.Contains(message, retryable) {
+			return txErrorRetryable
+		}
+	}
+	return txErrorRetryable
+}
+
+// pendingValue is a value waiting to be written to the oracle.
+type pendingValue struct {
+	value     *big.Int
+	published float64
+	symbol    string
+}
+
+// txManager writes oracle updates and retries failed updates with exponential backoff.
+// Values that were not written yet are kept per key, such that a newer value of a key supersedes the stale one.
+// Pending values are sent in order of priority, split by the batcher into transactions within the gas limits.
+// Values that do not fit into the gas budget of a round are sent in the next round.
+// All methods must be called from the goroutine running OracleUpdateExecutor.
+type txManager struct {
+	// update sends a transaction writing @values of @keys at @timestamp to the oracle.
+	update func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (*types.Transaction, error)
+	// batcher is nil if all pending values are sent in one transaction.
+	batcher *batcher
+	state   *publicationState
+
+	pending   map[string]pendingValue
+	timestamp int64
+	failures  int
+	// retry fires when the next attempt is due. It is nil while no attempt is scheduled.
+	retry          <-chan time.Time
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newTxManager(
+	nodes *NodePool,
+	auth *bind.TransactOpts,
+	gas GasStrategy,
+	state *publicationState,
+	nonces *nonceManager,
+) (*txManager, error) {
+	batcher, err := newBatcher(nodes, func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (uint64, error) {
+		gas, err := estimateOracleMultiValues(ctx, nodes, auth, keys, values, timestamp)
+		if err != nil {
+			nodes.reportError(err)
+		}
+		return gas, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	oracleUpdateState.Set(float64(txStateOK))
+	return &txManager{
+		update: func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (*types.Transaction, error) {
+			return updateOracleMultiValues(ctx, nodes, auth, gas, nonces, keys, values, timestamp)
+		},
+		batcher:        batcher,
+		state:          state,
+		pending:        make(map[string]pendingValue),
+		initialBackoff: time.Duration(utils.GetenvFloat("TX_RETRY_INITIAL_BACKOFF_SECONDS", 2)*1000) * time.Millisecond,
+		maxBackoff:     time.Duration(utils.GetenvFloat("TX_RETRY_MAX_BACKOFF_SECONDS", 120)*1000) * time.Millisecond,
+	}, nil
+}
+
+// enqueue adds @values of @keys at @timestamp to the pending values and sends them, unless a retry is scheduled.
+func (m *txManager) enqueue(ctx context.Context, keys []string, values []pendingValue, timestamp int64) {
+	for i, key := range keys {
+		if _, ok := m.pending[key]; ok {
+			oracleSupersededValues.Inc()
+		}
+		m.pending[key] = values[i]
+	}
+	m.timestamp = timestamp
+	if m.retry == nil {
+		m.send(ctx)
+	}
+}
+
+// onRetry is called when the scheduled retry is due.
+func (m *txManager) onRetry(ctx context.Context) {
+	m.retry = nil
+	m.send(ctx)
+}
+
+// send writes the pending values. On failure, the values that were not written are kept and a retry is scheduled.
+func (m *txManager) send(ctx context.Context) {
+	if len(m.pending) == 0 {
+		return
+	}
+	now := time.Unix(m.timestamp, 0)
+	keys := make([]string, 0, len(m.pending))
+	priorities := make(map[string]float64, len(m.pending))
+	for key, pending := range m.pending {
+		keys = append(keys, key)
+		priorities[key] = m.state.priority(key, pending.symbol, pending.published, now)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if priorities[keys[i]] != priorities[keys[j]] {
+			return priorities[keys[i]] > priorities[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	values := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		values[i] = m.pending[key].value
+	}
+
+	batches := []batch{{keys: keys, values: values}}
+	var err error
+	if m.batcher != nil {
+		batches, err = m.batcher.split(ctx, keys, values, m.timestamp)
+	}
+	for _, b := range batches {
+		var tx *types.Transaction
+		tx, err = m.update(ctx, b.keys, b.values, m.timestamp)
+		if err == nil && tx == nil {
+			err = errors.New("no transaction was sent")
+		}
+		if err != nil {
+			break
+		}
+		publishedValues := make([]float64, len(b.keys))
+		for i, key := range b.keys {
+			publishedValues[i] = m.pending[key].published
+			delete(m.pending, key)
+		}
+		m.state.setPublished(b.keys, publishedValues, now)
+		oracleUpdates.Inc()
+	}
+	if err != nil {
+		class := classifyTxError(err)
+		m.failures++
+		oracleUpdateFailures.WithLabelValues(string(class)).Inc()
+		delay := m.backoff()
+		if class == txErrorFatal {
+			delay = m.maxBackoff
+			oracleUpdateState.Set(float64(txStateFailing))
+			log.Errorf("updater - Failed to update Oracle with %v keys (%s, attempt %v): %v. Retry in %v.", len(m.pending), class, m.failures, err, delay)
+		} else {
+			oracleUpdateState.Set(float64(txStateRetrying))
+			log.Warnf("updater - Failed to update Oracle with %v keys (%s, attempt %v): %v. Retry in %v.", len(m.pending), class, m.failures, err, delay)
+		}
+		m.retry = time.After(delay)
+		return
+	}
+
+	m.failures = 0
+	oracleUpdateState.Set(float64(txStateOK))
+	if len(m.pending) > 0 {
+		log.Infof("updater - %v keys do not fit into the gas budget and are deferred to the next round.", len(m.pending))
+		oracleDeferredValues.Add(float64(len(m.pending)))
+		m.retry = time.After(m.initialBackoff)
+	}
+}
+
+// backoff returns the delay before the next attempt after m.failures consecutive failures.
+func (m *txManager) backoff() time.Duration {
+	delay := float64(m.initialBackoff) * math.Pow(2, float64(m.failures-1))
+	if delay > float64(m.maxBackoff) {
+		return m.maxBackoff
+	}
+	return time.Duration(delay)
+}
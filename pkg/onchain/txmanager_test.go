@@ -0,0 +1,83 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestClassifyTxError(t *testing.T) {
+	cases := map[string]txErrorClass{
+		"nonce too low":                              txErrorRetryable,
+		"replacement transaction underpriced":        txErrorRetryable,
+		"Post \"https://rpc\": i/o timeout":          txErrorRetryable,
+		"insufficient funds for gas * price + value": txErrorFatal,
+		"execution reverted: not the oracle updater": txErrorFatal,
+		"something unexpected":                       txErrorRetryable,
+	}
+	for message, want := range cases {
+		if got := classifyTxError(errors.New(message)); got != want {
+			t.Errorf("%s: got %s, want %s", message, got, want)
+		}
+	}
+}
+
+func TestTxManagerRetrySupersedes(t *testing.T) {
+	var (
+		sentKeys   [][]string
+		sentValues [][]*big.Int
+		fail       = true
+	)
+	m := &txManager{
+		update: func(ctx context.Context, keys []string, values []*big.Int, timestamp int64) (*types.Transaction, error) {
+			sentKeys = append(sentKeys, keys)
+			sentValues = append(sentValues, values)
+			if fail {
+				return nil, errors.New("nonce too low")
+			}
+			return types.NewTx(&types.LegacyTx{}), nil
+		},
+		state:          newPublicationState(nil),
+		pending:        make(map[string]pendingValue),
+		initialBackoff: time.Second,
+		maxBackoff:     10 * time.Second,
+	}
+
+	m.enqueue(context.Background(), []string{"BTC/USD", "ETH/USD"}, []pendingValue{{value: big.NewInt(1), published: 1}, {value: big.NewInt(2), published: 2}}, 100)
+	if m.retry == nil || m.failures != 1 || len(m.pending) != 2 {
+		t.Fatalf("failed update must be kept and retried")
+	}
+	// While a retry is scheduled, new values only update the pending values.
+	m.enqueue(context.Background(), []string{"BTC/USD"}, []pendingValue{{value: big.NewInt(3), published: 3}}, 120)
+	if len(sentKeys) != 1 {
+		t.Fatalf("got %v attempts, want 1", len(sentKeys))
+	}
+
+	fail = false
+	m.onRetry(context.Background())
+	if !reflect.DeepEqual(sentKeys[1], []string{"BTC/USD", "ETH/USD"}) || !reflect.DeepEqual(sentValues[1], []*big.Int{big.NewInt(3), big.NewInt(2)}) {
+		t.Errorf("got %v %v, want the newest values of both keys", sentKeys[1], sentValues[1])
+	}
+	if m.retry != nil || m.failures != 0 || len(m.pending) != 0 || len(sentKeys) != 2 {
+		t.Error("successful update must clear the pending values")
+	}
+	if last := m.state.published["BTC/USD"]; last.Value != 3 || last.Timestamp.Unix() != 120 {
+		t.Errorf("got published %v, want 3 at 120", last)
+	}
+}
+
+func TestTxManagerBackoff(t *testing.T) {
+	m := &txManager{initialBackoff: time.Second, maxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		m.failures = i + 1
+		if got := m.backoff(); got != w {
+			t.Errorf("failure %v: got %v, want %v", i+1, got, w)
+		}
+	}
+}
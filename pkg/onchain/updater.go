@@ -2,22 +2,22 @@ package onchain
 
 import (
 	"context"
-	"io/ioutil"
 	"math/big"
-	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/diadata-org/decentral-feeder/pkg/models"
 	"github.com/diadata-org/decentral-feeder/pkg/utils"
-	diaOracleV2MultiupdateService "github.com/diadata-org/diadata/pkg/dia/scraper/blockchain-scrapers/blockchains/ethereum/diaOracleV2MultiupdateService"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/sirupsen/logrus"
-	"github.com/tidwall/gjson"
 )
 
 var (
 	log *logrus.Logger
+	// shutdownTimeout bounds the time for draining the pipeline and for mining the last transaction on shutdown.
+	shutdownTimeout time.Duration
 )
 
 func init() {
@@ -27,117 +27,265 @@ func init() {
 		log.Errorf("Parse log level: %v.", err)
 	}
 	log.SetLevel(loglevel)
+
+	shutdownTimeoutSeconds, err := strconv.Atoi(utils.Getenv("SHUTDOWN_TIMEOUT_SECONDS", "30"))
+	if err != nil {
+		log.Errorf("Parse SHUTDOWN_TIMEOUT_SECONDS: %v.", err)
+		shutdownTimeoutSeconds = 30
+	}
+	shutdownTimeout = time.Duration(shutdownTimeoutSeconds) * time.Second
 }
 
+// OracleUpdateExecutor writes the filter values received from @filtersChannel to the oracle. Only values that
+// qualify under the publication policy of their asset are written, see PublicationPolicy. Fees are set by @gas.
+// Failed updates are retried with backoff by the txManager, so the executor keeps running on RPC or account errors.
+// Sent transactions are watched every TX_WATCH_SECONDS by the nonceManager, which replaces stuck transactions.
+// On cancellation of @ctx, it waits for the pipeline to deliver the final values and close @filtersChannel,
+// and for the sent transactions to be mined, each for at most SHUTDOWN_TIMEOUT_SECONDS. RPC calls keep the values of
+// @ctx, but not its cancellation, so that the final values can still be written. Each call is bounded by the node
+// timeout and all calls are cancelled when the executor returns.
+// With @dryRun, updates are built and signed, but logged and recorded instead of sent, see dryRun.
+// The latest published values of @feeds are read from the oracle at startup.
 func OracleUpdateExecutor(
-	// publishedPrices map[string]float64,
-	// newPrices map[string]float64,
-	// deviationPermille int,
+	ctx context.Context,
 	auth *bind.TransactOpts,
-	contract *diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService,
-	conn *ethclient.Client,
-	chainId int64,
+	nodes *NodePool,
+	gas GasStrategy,
+	dryRun bool,
 	// compatibilityMode bool,
+	feeds []models.Feed,
 	filtersChannel <-chan []models.FilterPointExtended,
 ) {
 
-	for filterPoints := range filtersChannel {
-		timestamp := time.Now().Unix()
-		var keys []string
-		var values []int64
+	rpcCtx, cancelRPC := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRPC()
+	var (
+		done             = ctx.Done()
+		shutdownDeadline <-chan time.Time
+		state            = newPublicationState(nodes)
+		watch            = time.NewTicker(time.Duration(utils.GetenvFloat("TX_WATCH_SECONDS", 5)*1000) * time.Millisecond)
+	)
+	defer watch.Stop()
+	confirmations, err := newConfirmationTracker(nodes, state)
+	if err != nil {
+		log.Fatalf("updater - %v.", err)
+	}
+	nonces := newNonceManager(nodes, auth, gas, confirmations)
+	manager, err := newTxManager(nodes, auth, gas, state, nonces)
+	if err != nil {
+		log.Fatalf("updater - %v.", err)
+	}
+	if dryRun {
+		d, err := newDryRun(nodes, auth, gas)
+		if err != nil {
+			log.Fatalf("updater - Dry run: %v.", err)
+		}
+		defer d.Close()
+		// Every update is recorded in full, as the gas of a dry run does not need to fit into a block.
+		manager.update = d.update
+		manager.batcher = nil
+		log.Warn("updater - Dry run: transactions are not sent.")
+	} else {
+		// A dry run does not depend on the chain, so it starts without the published values.
+		state.load(rpcCtx, feeds)
+	}
+
+	for {
+		var filterPoints []models.FilterPointExtended
+		select {
+		case fps, ok := <-filtersChannel:
+			if !ok {
+				// Make a last attempt to write values that are still pending.
+				if manager.retry != nil {
+					manager.onRetry(rpcCtx)
+				}
+				waitForPendingTxs(rpcCtx, nonces)
+				return
+			}
+			filterPoints = fps
+		case <-manager.retry:
+			manager.onRetry(rpcCtx)
+			continue
+		case <-watch.C:
+			nonces.watch(rpcCtx)
+			continue
+		case <-done:
+			log.Info("updater - Shutting down. Wait for final filter values.")
+			done = nil
+			shutdownDeadline = time.After(shutdownTimeout)
+			continue
+		case <-shutdownDeadline:
+			log.Warn("updater - Pipeline not drained before shutdown timeout.")
+			waitForPendingTxs(rpcCtx, nonces)
+			return
+		}
+
+		now := time.Now()
+		timestamp := now.Unix()
+		var (
+			keys   []string
+			values []pendingValue
+		)
 		for _, fp := range filterPoints {
 			log.Infof(
 				"updater - filterPoint received at %v: %v -- %v -- %v -- %v.",
 				time.Unix(timestamp, 0),
 				fp.Source,
-				fp.Pair.QuoteToken.Symbol,
+				fp.Feed().Key(),
 				fp.Value,
 				fp.Time,
 			)
-			keys = append(keys, fp.Pair.QuoteToken.Symbol+"/USD")
-			values = append(values, int64(fp.Value*100000000))
+			key := fp.Feed().Key()
+			if !state.shouldPublish(rpcCtx, key, fp.Pair.QuoteToken.Symbol, fp.Value, now) {
+				log.Debugf("updater - %s does not qualify for publication.", key)
+				continue
+			}
+			value, err := encodeValue(fp.Value, state.decimals(key, fp.Pair.QuoteToken.Symbol))
+			if err != nil {
+				log.Errorf("updater - Encode %s: %v.", key, err)
+				oracleInvalidValues.WithLabelValues(key).Inc()
+				continue
+			}
+			keys = append(keys, key)
+			values = append(values, pendingValue{value: value, published: fp.Value, symbol: fp.Pair.QuoteToken.Symbol})
 		}
-		err := updateOracleMultiValues(conn, contract, auth, chainId, keys, values, timestamp)
-		if err != nil {
-			log.Warnf("updater - Failed to update Oracle: %v.", err)
-			return
+		if len(keys) == 0 {
+			log.Info("updater - No value qualifies for publication.")
+			continue
 		}
+		manager.enqueue(rpcCtx, keys, values, timestamp)
 	}
 
 }
 
+// waitForPendingTxs waits at most @shutdownTimeout for the transactions sent through @nonces to be mined.
+// Stuck transactions are still replaced while waiting.
+func waitForPendingTxs(ctx context.Context, nonces *nonceManager) {
+	if len(nonces.inflight) == 0 {
+		return
+	}
+	log.Infof("updater - Wait for %v transactions to be mined.", len(nonces.inflight))
+	deadline := time.After(shutdownTimeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for len(nonces.inflight) > 0 {
+		select {
+		case <-ticker.C:
+			nonces.watch(ctx)
+		case <-deadline:
+			log.Warnf("updater - %v transactions not mined before shutdown, the first with nonce %v.", len(nonces.inflight), nonces.inflight[0].nonce)
+			return
+		}
+	}
+	log.Info("updater - All transactions mined.")
+}
+
+// updateOracleMultiValues signs a transaction writing @values of @keys at @timestamp with the next nonce of @nonces
+// and fees from @gas through the active node of @nodes and broadcasts it, failing over to backup nodes if necessary.
+// If the fees exceed MAX_GAS_PRICE_GWEI, no transaction is sent and errGasPriceTooHigh is returned.
 func updateOracleMultiValues(
-	client *ethclient.Client,
-	contract *diaOracleV2MultiupdateService.DiaOracleV2MultiupdateService,
+	ctx context.Context,
+	nodes *NodePool,
 	auth *bind.TransactOpts,
-	chainId int64,
+	gas GasStrategy,
+	nonces *nonceManager,
 	keys []string,
-	values []int64,
-	timestamp int64) error {
-
-	var cValues []*big.Int
-	var gasPrice *big.Int
-	var err error
-
-	// Get proper gas price depending on chainId
-	switch chainId {
-	/*case 288: //Bobapkg/scraper/Uniswapv2.go
-	gasPrice = big.NewInt(1000000000)*/
-	case 592: //Astar
-		response, err := http.Get("https://gas.astar.network/api/gasnow?network=astar")
-		if err != nil {
-			return err
-		}
+	values []*big.Int,
+	timestamp int64) (*types.Transaction, error) {
 
-		defer response.Body.Close()
-		if 200 != response.StatusCode {
-			return err
-		}
-		contents, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return err
-		}
+	node := nodes.Active()
 
-		gasSuggestion := gjson.Get(string(contents), "data.fast")
-		gasPrice = big.NewInt(gasSuggestion.Int())
-	default:
-		// Get gas price suggestion
-		gasPrice, err = client.SuggestGasPrice(context.Background())
-		if err != nil {
-			log.Errorf("updater - SuggestGasPrice: %v.", err)
-			return err
-		}
+	callCtx, cancel := context.WithTimeout(ctx, nodes.timeout)
+	fees, err := gas.Fees(callCtx, node.Client)
+	cancel()
+	if err != nil {
+		log.Errorf("updater - Gas fees: %v.", err)
+		nodes.reportError(err)
+		return nil, err
+	}
 
-		// Get 110% of the gas price
-		fGas := new(big.Float).SetInt(gasPrice)
-		fGas.Mul(fGas, big.NewFloat(1.1))
-		gasPrice, _ = fGas.Int(nil)
+	nonce, err := nonces.nonce(ctx)
+	if err != nil {
+		log.Errorf("updater - NonceAt: %v.", err)
+		nodes.reportError(err)
+		return nil, err
+	}
+	cValues, err := packValues(values, timestamp)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, value := range values {
-		// Create compressed argument with values/timestamps
-		cValue := big.NewInt(value)
-		cValue = cValue.Lsh(cValue, 128)
-		cValue = cValue.Add(cValue, big.NewInt(timestamp))
-		cValues = append(cValues, cValue)
+	// Raise the fees if an earlier attempt at this nonce was underpriced.
+	fees, err = nonces.fees(nonce, fees)
+	if err != nil {
+		return nil, err
 	}
+	oracleGasPrice.Set(gweiFloat(fees.Max()))
 
-	// Write values to smart contract
-	tx, err := contract.SetMultipleValues(&bind.TransactOpts{
-		From:     auth.From,
-		Signer:   auth.Signer,
-		GasPrice: gasPrice,
+	// Sign the transaction once, so that the same transaction can be rebroadcast through backup nodes.
+	// Its gas is estimated by the node.
+	callCtx, cancel = context.WithTimeout(ctx, nodes.timeout)
+	defer cancel()
+	tx, err := node.Contract.SetMultipleValues(&bind.TransactOpts{
+		From:      auth.From,
+		Signer:    auth.Signer,
+		Nonce:     new(big.Int).SetUint64(nonce),
+		GasPrice:  fees.GasPrice,
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
+		NoSend:    true,
+		Context:   callCtx,
 	}, keys, cValues)
-	// check if tx is sendable then fgo backup
 	if err != nil {
-		// backup in here
-		return err
+		nodes.reportError(err)
+		return nil, err
+	}
+	err = nodes.SendTransaction(ctx, tx)
+	if err != nil {
+		nonces.failed(nonce, err)
+		return nil, err
 	}
+	nonces.sent(tx, sentUpdate{keys: keys, values: values, timestamp: timestamp})
 
-	log.Infof("updater - Gas price: %d.", tx.GasPrice())
+	if fees.Dynamic() {
+		log.Infof("updater - Gas tip cap: %d, fee cap: %d.", tx.GasTipCap(), tx.GasFeeCap())
+	} else {
+		log.Infof("updater - Gas price: %d.", tx.GasPrice())
+	}
 	// log.Printf("Data: %x\n", tx.Data())
 	log.Infof("updater - Nonce: %d.", tx.Nonce())
 	log.Infof("updater - Tx To: %s.", tx.To().String())
 	log.Infof("updater - Tx Hash: 0x%x.", tx.Hash())
-	return nil
+	return tx, nil
+}
+
+// estimateOracleMultiValues returns the gas of a transaction writing @values of @keys at @timestamp, as estimated
+// by the active node of @nodes.
+func estimateOracleMultiValues(
+	ctx context.Context,
+	nodes *NodePool,
+	auth *bind.TransactOpts,
+	keys []string,
+	values []*big.Int,
+	timestamp int64) (uint64, error) {
+
+	cValues, err := packValues(values, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, nodes.timeout)
+	defer cancel()
+	// The transaction is only built for its gas estimate, so it is neither signed nor sent.
+	tx, err := nodes.Active().Contract.SetMultipleValues(&bind.TransactOpts{
+		From:     auth.From,
+		Signer:   func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) { return tx, nil },
+		Nonce:    big.NewInt(0),
+		GasPrice: big.NewInt(0),
+		NoSend:   true,
+		Context:  ctx,
+	}, keys, cValues)
+	if err != nil {
+		return 0, err
+	}
+	return tx.Gas(), nil
 }
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"strings"
 	"sync"
 	"time"
@@ -15,19 +16,33 @@ import (
 // More precisley, it does so in a 2 step procedure:
 // 1. Aggregate trades for each (atomic) block.
 // 2. Aggregate filter values obtained in step 1.
+// On cancellation of @ctx, the Collector sends a final tradesblock and closes @tradesblockChannel.
+// The Processor then handles this block and closes @filtersChannel.
 func Processor(
+	ctx context.Context,
 	exchangePairs []models.ExchangePair,
 	pools []models.Pool,
 	tradesblockChannel chan map[string]models.TradesBlock,
 	filtersChannel chan []models.FilterPointExtended,
 	triggerChannel chan time.Time,
-	failoverChannel chan string,
 	wg *sync.WaitGroup,
 ) {
 
 	log.Info("Processor - Start......")
 	// Collector starts collecting trades in the background and sends atomic tradesblocks to @tradesblockChannel.
-	go scrapers.Collector(exchangePairs, pools, tradesblockChannel, triggerChannel, failoverChannel, wg)
+	go scrapers.Collector(ctx, exchangePairs, pools, tradesblockChannel, triggerChannel, wg)
+
+	ProcessTradesblocks(tradesblockChannel, filtersChannel, time.Now)
+}
+
+// ProcessTradesblocks computes the filter values of each set of tradesblocks from @tradesblockChannel
+// and sends them to @filtersChannel. Filter values older than @now() minus the tolerance are discarded.
+// It closes @filtersChannel once @tradesblockChannel is closed.
+func ProcessTradesblocks(
+	tradesblockChannel chan map[string]models.TradesBlock,
+	filtersChannel chan []models.FilterPointExtended,
+	now func() time.Time,
+) {
 
 	// As soon as the trigger channel receives input a processing step is initiated.
 	for tradesblocks := range tradesblockChannel {
@@ -54,32 +69,49 @@ func Processor(
 
 			// Identify Pair from tradesblock
 			filterPoint := models.FilterPointExtended{
-				Pair:   tb.Pair,
-				Value:  atomicFilterValue,
-				Time:   tb.EndTime,
-				Source: strings.Split(exchangepairIdentifier, "-")[0],
+				Pair:         tb.Pair,
+				Value:        atomicFilterValue,
+				Time:         tb.EndTime,
+				Source:       strings.Split(exchangepairIdentifier, "-")[0],
+				LiquidityUSD: models.GetLastTrade(tb.Trades).LiquidityUSD,
+				Notional:     models.GetLastTrade(tb.Trades).Notional,
 			}
 			filterPoints = append(filterPoints, filterPoint)
 
 		}
 
 		var removedFilterPoints int
-		filterPoints, removedFilterPoints = models.RemoveOldFilters(filterPoints, toleranceSeconds, time.Now())
+		filterPoints, removedFilterPoints = models.RemoveOldFilters(filterPoints, toleranceSeconds, now())
 		if removedFilterPoints > 0 {
 			log.Warnf("Processor - Removed %v old filter points.", removedFilterPoints)
 		}
 
+		// Exclude DEX sources whose pools are too illiquid to be reliable.
+		if dexMinLiquidityUSD > 0 {
+			filterPoints, removedFilterPoints = models.RemoveIlliquidFilters(filterPoints, dexMinLiquidityUSD)
+			if removedFilterPoints > 0 {
+				log.Warnf("Processor - Removed %v filter points with liquidity below %v USD.", removedFilterPoints, dexMinLiquidityUSD)
+			}
+		}
+
 		// --------------------------------------------------------------------------------------------
 		// 2. Compute an aggregated value across exchanges for each asset obtained from the aggregated
 		// filter values in Step 1.
 		// --------------------------------------------------------------------------------------------
 		// TO DO: Set flag for metafilter switch. For instance Median, Average, Minimum, etc.
-		filterPointsMedianized := metafilters.Median(filterPoints)
+		var filterPointsMedianized []models.FilterPointExtended
+		if dexReferenceLiquidityUSD > 0 {
+			filterPointsMedianized = metafilters.LiquidityWeightedMedian(filterPoints, dexReferenceLiquidityUSD)
+		} else {
+			filterPointsMedianized = metafilters.Median(filterPoints)
+		}
 		for _, fpm := range filterPointsMedianized {
-			log.Infof("Processor - filter %s for %s: %v.", fpm.Name, fpm.Pair.QuoteToken.Symbol, fpm.Value)
+			log.Infof("Processor - filter %s for %s: %v.", fpm.Name, fpm.Feed().Key(), fpm.Value)
 		}
 
 		filtersChannel <- filterPointsMedianized
 	}
 
+	log.Info("Processor - Stopped.")
+	close(filtersChannel)
 }
@@ -8,9 +8,13 @@ import (
 )
 
 // For processing, all filters with timestamp older than time.Now()-toleranceSeconds are discarded.
+// Filters from DEX pools with liquidity below dexMinLiquidityUSD are discarded as well.
+// If dexReferenceLiquidityUSD is positive, filters from DEX pools are weighted by their liquidity relative to it.
 var (
-	toleranceSeconds int64
-	log              *logrus.Logger
+	toleranceSeconds         int64
+	dexMinLiquidityUSD       float64
+	dexReferenceLiquidityUSD float64
+	log                      *logrus.Logger
 )
 
 func init() {
@@ -27,4 +31,14 @@ func init() {
 		log.Errorf("Parse TOLERANCE_SECONDS environment variable: %v.", err)
 	}
 
+	dexMinLiquidityUSD, err = strconv.ParseFloat(utils.Getenv("DEX_MIN_LIQUIDITY_USD", "0"), 64)
+	if err != nil {
+		log.Errorf("Parse DEX_MIN_LIQUIDITY_USD environment variable: %v.", err)
+	}
+
+	dexReferenceLiquidityUSD, err = strconv.ParseFloat(utils.Getenv("DEX_REFERENCE_LIQUIDITY_USD", "0"), 64)
+	if err != nil {
+		log.Errorf("Parse DEX_REFERENCE_LIQUIDITY_USD environment variable: %v.", err)
+	}
+
 }
@@ -0,0 +1,88 @@
+// Package replay feeds recorded trades through the Collector and the Processor on a simulated clock,
+// so that the filter values of a past period can be recomputed deterministically.
+package replay
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/processor"
+	"github.com/diadata-org/decentral-feeder/pkg/scrapers"
+)
+
+// Clock is a simulated clock that only advances when it is set.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the current time of @clock.
+func (clock *Clock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+// Set advances @clock to @t.
+func (clock *Clock) Set(t time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = t
+}
+
+// Result are the filter values that would have been published at Time.
+type Result struct {
+	Time         time.Time
+	FilterPoints []models.FilterPointExtended
+}
+
+// Run replays @trades in chronological order and triggers the Collector every @frequency, starting with the
+// first multiple of @frequency after the first trade. After the last trade, a final trigger is sent.
+// @fn is called with the filter values of each trigger in order.
+func Run(trades []models.Trade, frequency time.Duration, fn func(Result) error) error {
+	if len(trades) == 0 {
+		return nil
+	}
+	sorted := make([]models.Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	// Trades and triggers are sent over unbuffered channels by this goroutine only, so the Collector receives
+	// them in the same order. The filter values of a trigger are awaited before the next trade is sent.
+	var clock Clock
+	tradesChannel := make(chan models.Trade)
+	triggerChannel := make(chan time.Time)
+	tradesblockChannel := make(chan map[string]models.TradesBlock)
+	filtersChannel := make(chan []models.FilterPointExtended)
+	ctx, cancel := context.WithCancel(context.Background())
+	go scrapers.CollectTrades(ctx, tradesChannel, tradesblockChannel, triggerChannel, nil, clock.Now)
+	go processor.ProcessTradesblocks(tradesblockChannel, filtersChannel, clock.Now)
+	// The final block the Collector sends on shutdown is not part of the replay and is discarded.
+	defer func() {
+		cancel()
+		for range filtersChannel {
+		}
+	}()
+
+	trigger := func(t time.Time) error {
+		clock.Set(t)
+		triggerChannel <- t
+		return fn(Result{Time: t, FilterPoints: <-filtersChannel})
+	}
+
+	next := sorted[0].Time.Truncate(frequency).Add(frequency)
+	clock.Set(sorted[0].Time)
+	for _, trade := range sorted {
+		for trade.Time.After(next) {
+			if err := trigger(next); err != nil {
+				return err
+			}
+			next = next.Add(frequency)
+		}
+		tradesChannel <- trade
+	}
+	return trigger(next)
+}
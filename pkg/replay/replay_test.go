@@ -0,0 +1,84 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+)
+
+func TestRun(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	btc := models.Asset{Symbol: "BTC", Blockchain: "Bitcoin", Address: "0x0000000000000000000000000000000000000000"}
+	usd := models.Asset{Symbol: "USD", Blockchain: "Fiat", Address: "840"}
+	trade := func(exchange string, price float64, seconds int) models.Trade {
+		return models.Trade{
+			QuoteToken: btc,
+			BaseToken:  usd,
+			Exchange:   models.Exchange{Name: exchange},
+			Price:      price,
+			Time:       t0.Add(time.Duration(seconds) * time.Second),
+		}
+	}
+	// Trades are given out of order. The first window ends at t0+20s.
+	trades := []models.Trade{
+		trade("Kraken", 110, 25),
+		trade("Binance", 100, 3),
+		trade("CoinBase", 104, 5),
+		trade("Kraken", 102, 12),
+		trade("Binance", 106, 27),
+		trade("CoinBase", 108, 61),
+	}
+
+	run := func() []Result {
+		var results []Result
+		err := Run(trades, 20*time.Second, func(result Result) error {
+			results = append(results, result)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	results := run()
+	want := []struct {
+		seconds int
+		value   float64
+	}{
+		{20, 102},
+		{40, 108},
+		{60, 0},
+		{80, 108},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %v results, want %v", len(results), len(want))
+	}
+	for i, w := range want {
+		if !results[i].Time.Equal(t0.Add(time.Duration(w.seconds) * time.Second)) {
+			t.Errorf("result %v: got time %v", i, results[i].Time)
+		}
+		if w.value == 0 {
+			if len(results[i].FilterPoints) != 0 {
+				t.Errorf("result %v: got %v, want no filter values", i, results[i].FilterPoints)
+			}
+			continue
+		}
+		if len(results[i].FilterPoints) != 1 || results[i].FilterPoints[0].Value != w.value {
+			t.Errorf("result %v: got %v, want %v", i, results[i].FilterPoints, w.value)
+		}
+	}
+
+	// A second replay of the same trades yields the same values.
+	for i, result := range run() {
+		if len(result.FilterPoints) != len(results[i].FilterPoints) {
+			t.Fatalf("result %v differs between replays", i)
+		}
+		for j := range result.FilterPoints {
+			if result.FilterPoints[j].Value != results[i].FilterPoints[j].Value {
+				t.Errorf("result %v differs between replays", i)
+			}
+		}
+	}
+}
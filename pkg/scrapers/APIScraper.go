@@ -4,7 +4,6 @@ import (
 	"context"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	models "github.com/diadata-org/decentral-feeder/pkg/models"
@@ -18,7 +17,8 @@ type Scraper interface {
 	// Subscribe(pair models.ExchangePair, subscribe bool, lock *sync.RWMutex) error
 }
 
-// RunScraper starts a scraper for @exchange.
+// RunScraper runs a scraper for @exchange until @ctx is cancelled or the scraper fails. A failure is
+// reported once by sending @exchange to @failoverChannel.
 func RunScraper(
 	ctx context.Context,
 	exchange string,
@@ -26,197 +26,89 @@ func RunScraper(
 	pools []models.Pool,
 	tradesChannel chan models.Trade,
 	failoverChannel chan string,
-	wg *sync.WaitGroup,
 ) {
 	switch exchange {
 	case BINANCE_EXCHANGE:
-
-		ctx, cancel := context.WithCancel(context.Background())
-		scraper := NewBinanceScraper(ctx, pairs, failoverChannel, wg)
-
-		watchdogDelay, err := strconv.Atoi(utils.Getenv("BINANCE_WATCHDOG", "300"))
-		if err != nil {
-			log.Errorf("parse BINANCE_WATCHDOG: %v.", err)
-		}
-		watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-		lastTradeTime := time.Now()
-
-		for {
-			select {
-			case trade := <-scraper.TradesChannel():
-				lastTradeTime = time.Now()
-				tradesChannel <- trade
-
-			case <-watchdogTicker.C:
-				duration := time.Since(lastTradeTime)
-				if duration > time.Duration(watchdogDelay)*time.Second {
-					err := scraper.Close(cancel)
-					if err != nil {
-						log.Errorf("Binance - Close(): %v.", err)
-					}
-					log.Warnf("Closed Binance scraper as duration since last trade is %v.", duration)
-					failoverChannel <- BINANCE_EXCHANGE
-					return
-				}
-			}
-		}
-
+		runCEXScraper(ctx, exchange, "BINANCE_WATCHDOG", NewBinanceScraper, pairs, tradesChannel, failoverChannel)
 	case COINBASE_EXCHANGE:
-		ctx, cancel := context.WithCancel(context.Background())
-		scraper := NewCoinBaseScraper(ctx, pairs, failoverChannel, wg)
-
-		watchdogDelay, err := strconv.Atoi(utils.Getenv("COINBASE_WATCHDOG", "300"))
-		if err != nil {
-			log.Errorf("parse COINBASE_WATCHDOG: %v.", err)
-		}
-		watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-		lastTradeTime := time.Now()
-
-		for {
-			select {
-			case trade := <-scraper.TradesChannel():
-				lastTradeTime = time.Now()
-				tradesChannel <- trade
-
-			case <-watchdogTicker.C:
-				duration := time.Since(lastTradeTime)
-				if duration > time.Duration(watchdogDelay)*time.Second {
-					err := scraper.Close(cancel)
-					if err != nil {
-						log.Errorf("CoinBase - Close(): %v.", err)
-					}
-					log.Warnf("Closed CoinBase scraper as duration since last trade is %v.", duration)
-					failoverChannel <- COINBASE_EXCHANGE
-					return
-				}
-			}
-		}
+		runCEXScraper(ctx, exchange, "COINBASE_WATCHDOG", NewCoinBaseScraper, pairs, tradesChannel, failoverChannel)
 	case CRYPTODOTCOM_EXCHANGE:
-		ctx, cancel := context.WithCancel(context.Background())
-		scraper := NewCryptodotcomScraper(ctx, pairs, failoverChannel, wg)
-
-		watchdogDelay, err := strconv.Atoi(utils.Getenv("CRYPTODOTCOM_WATCHDOG", "300"))
-		if err != nil {
-			log.Errorf("parse CRYPTODOTCOM_WATCHDOG: %v.", err)
-		}
-		watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-		lastTradeTime := time.Now()
-
-		for {
-			select {
-			case trade := <-scraper.TradesChannel():
-				lastTradeTime = time.Now()
-				tradesChannel <- trade
-
-			case <-watchdogTicker.C:
-				duration := time.Since(lastTradeTime)
-				if duration > time.Duration(watchdogDelay)*time.Second {
-					err := scraper.Close(cancel)
-					if err != nil {
-						log.Errorf("Crypto.com - Close(): %v.", err)
-					}
-					log.Warnf("Closed Crypto.com scraper as duration since last trade is %v.", duration)
-					failoverChannel <- CRYPTODOTCOM_EXCHANGE
-					return
-				}
-			}
-		}
+		runCEXScraper(ctx, exchange, "CRYPTODOTCOM_WATCHDOG", NewCryptodotcomScraper, pairs, tradesChannel, failoverChannel)
 	case GATEIO_EXCHANGE:
-		ctx, cancel := context.WithCancel(context.Background())
-		scraper := NewGateIOScraper(ctx, pairs, failoverChannel, wg)
-
-		watchdogDelay, err := strconv.Atoi(utils.Getenv("GATEIO_WATCHDOG", "300"))
-		if err != nil {
-			log.Errorf("parse GATEIO_WATCHDOG: %v.", err)
-		}
-		watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-		lastTradeTime := time.Now()
-
-		for {
-			select {
-			case trade := <-scraper.TradesChannel():
-				lastTradeTime = time.Now()
-				tradesChannel <- trade
-
-			case <-watchdogTicker.C:
-				duration := time.Since(lastTradeTime)
-				if duration > time.Duration(watchdogDelay)*time.Second {
-					err := scraper.Close(cancel)
-					if err != nil {
-						log.Errorf("GateIO - Close(): %v.", err)
-					}
-					log.Warnf("Closed GateIO scraper as duration since last trade is %v.", duration)
-					failoverChannel <- GATEIO_EXCHANGE
-					return
-				}
-			}
-		}
+		runCEXScraper(ctx, exchange, "GATEIO_WATCHDOG", NewGateIOScraper, pairs, tradesChannel, failoverChannel)
 	case KRAKEN_EXCHANGE:
-		ctx, cancel := context.WithCancel(context.Background())
-		scraper := NewKrakenScraper(ctx, pairs, failoverChannel, wg)
-
-		watchdogDelay, err := strconv.Atoi(utils.Getenv("KRAKEN_WATCHDOG", "300"))
-		if err != nil {
-			log.Errorf("parse KRAKEN_WATCHDOG: %v.", err)
-		}
-		watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-		lastTradeTime := time.Now()
-
-		for {
-			select {
-			case trade := <-scraper.TradesChannel():
-				lastTradeTime = time.Now()
-				tradesChannel <- trade
-
-			case <-watchdogTicker.C:
-				duration := time.Since(lastTradeTime)
-				if duration > time.Duration(watchdogDelay)*time.Second {
-					err := scraper.Close(cancel)
-					if err != nil {
-						log.Errorf("Kraken - Close(): %v.", err)
-					}
-					log.Warnf("Close Kraken scraper as duration since last trade is %v.", duration)
-					failoverChannel <- KRAKEN_EXCHANGE
-					return
-				}
-			}
-		}
+		runCEXScraper(ctx, exchange, "KRAKEN_WATCHDOG", NewKrakenScraper, pairs, tradesChannel, failoverChannel)
 	case KUCOIN_EXCHANGE:
-		ctx, cancel := context.WithCancel(context.Background())
-		scraper := NewKuCoinScraper(ctx, pairs, failoverChannel, wg)
+		runCEXScraper(ctx, exchange, "KUCOIN_WATCHDOG", NewKuCoinScraper, pairs, tradesChannel, failoverChannel)
 
-		watchdogDelay, err := strconv.Atoi(utils.Getenv("KUCOIN_WATCHDOG", "300"))
-		if err != nil {
-			log.Errorf("parse KUCOIN_WATCHDOG: %v.", err)
-		}
-		watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-		lastTradeTime := time.Now()
+	case UNISWAPV2_EXCHANGE:
+		NewUniswapV2Scraper(ctx, pools, tradesChannel, failoverChannel)
+	case UNISWAPV2_TWAP_EXCHANGE:
+		NewUniswapV2TWAPScraper(ctx, pools, tradesChannel, failoverChannel)
+	case UNISWAPV3_TWAP_EXCHANGE:
+		NewUniswapV3TWAPScraper(ctx, pools, tradesChannel, failoverChannel)
+	case Simulation:
+		NewSimulationScraper(ctx, pools, tradesChannel, failoverChannel)
+
+	default:
+		log.Errorf("%s - No scraper for exchange.", exchange)
+	}
+}
 
-		for {
-			select {
-			case trade := <-scraper.TradesChannel():
-				lastTradeTime = time.Now()
-				tradesChannel <- trade
+// runCEXScraper forwards the trades of the scraper built by @newScraper to @tradesChannel. The scraper fails if
+// it cannot be built or if no trade arrives within the delay given by @watchdogEnv.
+func runCEXScraper(
+	ctx context.Context,
+	exchange string,
+	watchdogEnv string,
+	newScraper func(ctx context.Context, pairs []models.ExchangePair) (Scraper, error),
+	pairs []models.ExchangePair,
+	tradesChannel chan models.Trade,
+	failoverChannel chan string,
+) {
+	ctx, cancel := context.WithCancel(ctx)
+	scraper, err := newScraper(ctx, pairs)
+	if err != nil {
+		cancel()
+		log.Errorf("%s - Start scraper: %v.", exchange, err)
+		failoverChannel <- exchange
+		return
+	}
 
-			case <-watchdogTicker.C:
-				duration := time.Since(lastTradeTime)
-				if duration > time.Duration(watchdogDelay)*time.Second {
-					err := scraper.Close(cancel)
-					if err != nil {
-						log.Errorf("KuCoin - Close(): %v.", err)
-					}
-					log.Warnf("Close KuCoin scraper as duration since last trade is %v.", duration)
-					failoverChannel <- KUCOIN_EXCHANGE
-					return
+	watchdogDelay, err := strconv.Atoi(utils.Getenv(watchdogEnv, "300"))
+	if err != nil {
+		log.Errorf("parse %s: %v.", watchdogEnv, err)
+	}
+	watchdogTicker := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
+	defer watchdogTicker.Stop()
+	lastTradeTime := time.Now()
+
+	for {
+		select {
+		case trade := <-scraper.TradesChannel():
+			lastTradeTime = time.Now()
+			sendTrade(ctx, exchange, tradesChannel, trade)
+
+		case <-ctx.Done():
+			// The instance was stopped by the supervisor.
+			err := scraper.Close(cancel)
+			if err != nil {
+				log.Errorf("%s - Close(): %v.", exchange, err)
+			}
+			return
+
+		case <-watchdogTicker.C:
+			duration := time.Since(lastTradeTime)
+			if duration > time.Duration(watchdogDelay)*time.Second {
+				err := scraper.Close(cancel)
+				if err != nil {
+					log.Errorf("%s - Close(): %v.", exchange, err)
 				}
+				log.Warnf("Closed %s scraper as duration since last trade is %v.", exchange, duration)
+				failoverChannel <- exchange
+				return
 			}
 		}
-
-	case UNISWAPV2_EXCHANGE:
-		NewUniswapV2Scraper(pools, tradesChannel, wg)
-	case Simulation:
-		NewSimulationScraper(pools, tradesChannel, wg)
-
 	}
 }
 
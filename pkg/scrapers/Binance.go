@@ -3,6 +3,7 @@ package scrapers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -53,13 +54,12 @@ var (
 	binanceApiWaitSeconds = 5
 )
 
-func NewBinanceScraper(ctx context.Context, pairs []models.ExchangePair, failoverChannel chan string, wg *sync.WaitGroup) Scraper {
-	defer wg.Done()
+func NewBinanceScraper(ctx context.Context, pairs []models.ExchangePair) (Scraper, error) {
 	var lock sync.RWMutex
 	log.Infof("Binance - Started scraper at %v.", time.Now())
 
 	scraper := binanceScraper{
-		tradesChannel:    make(chan models.Trade),
+		tradesChannel:    newTradesChannel(),
 		subscribeChannel: make(chan models.ExchangePair),
 		tickerPairMap:    models.MakeTickerPairMap(pairs),
 		lastTradeTimeMap: make(map[string]time.Time),
@@ -74,8 +74,7 @@ func NewBinanceScraper(ctx context.Context, pairs []models.ExchangePair, failove
 	for err != nil {
 
 		if errCount > 2*scraper.apiConnectRetries {
-			failoverChannel <- BINANCE_EXCHANGE
-			return &scraper
+			return nil, fmt.Errorf("connect to API: %w", err)
 		}
 
 		err = scraper.connectToAPI(pairs)
@@ -100,7 +99,7 @@ func NewBinanceScraper(ctx context.Context, pairs []models.ExchangePair, failove
 		go scraper.resubscribe(ctx, &lock)
 	}
 
-	return &scraper
+	return &scraper, nil
 
 }
 
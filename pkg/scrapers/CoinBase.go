@@ -2,6 +2,7 @@ package scrapers
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -51,13 +52,12 @@ var (
 	coinbaseWSBaseString = "wss://ws-feed.exchange.coinbase.com"
 )
 
-func NewCoinBaseScraper(ctx context.Context, pairs []models.ExchangePair, failoverChannel chan string, wg *sync.WaitGroup) Scraper {
-	defer wg.Done()
+func NewCoinBaseScraper(ctx context.Context, pairs []models.ExchangePair) (Scraper, error) {
 	var lock sync.RWMutex
 	log.Info("CoinBase - Started scraper.")
 
 	scraper := coinbaseScraper{
-		tradesChannel:    make(chan models.Trade),
+		tradesChannel:    newTradesChannel(),
 		subscribeChannel: make(chan models.ExchangePair),
 		tickerPairMap:    models.MakeTickerPairMap(pairs),
 		lastTradeTimeMap: make(map[string]time.Time),
@@ -70,9 +70,7 @@ func NewCoinBaseScraper(ctx context.Context, pairs []models.ExchangePair, failov
 	var wsDialer ws.Dialer
 	wsClient, _, err := wsDialer.Dial(coinbaseWSBaseString, nil)
 	if err != nil {
-		log.Errorf("CoinBase - Dial ws base string: %v.", err)
-		failoverChannel <- string(COINBASE_EXCHANGE)
-		return &scraper
+		return nil, fmt.Errorf("dial ws base string: %w", err)
 	}
 	scraper.wsClient = wsClient
 
@@ -100,7 +98,7 @@ func NewCoinBaseScraper(ctx context.Context, pairs []models.ExchangePair, failov
 		go scraper.resubscribe(ctx, &lock)
 	}
 
-	return &scraper
+	return &scraper, nil
 }
 
 func (scraper *coinbaseScraper) Close(cancel context.CancelFunc) error {
@@ -2,20 +2,24 @@ package scrapers
 
 import (
 	"context"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/diadata-org/decentral-feeder/pkg/archive"
 	models "github.com/diadata-org/decentral-feeder/pkg/models"
 )
 
 // Collector starts scrapers for all exchanges given by @exchangePairs.
+// On cancellation of @ctx, it stops all scrapers, sends the trades collected so far as a final
+// block and closes @tradesblockChannel.
 // Outlook: Collector starts a dedicated pod for each scraper.
 func Collector(
+	ctx context.Context,
 	exchangePairs []models.ExchangePair,
 	pools []models.Pool,
 	tradesblockChannel chan map[string]models.TradesBlock,
 	triggerChannel chan time.Time,
-	failoverChannel chan string,
 	wg *sync.WaitGroup,
 ) {
 
@@ -26,66 +30,114 @@ func Collector(
 	poolMap := models.MakePoolMap(pools)
 	log.Debugf("Collector - poolMap: %v.", poolMap)
 
-	// Start all needed scrapers.
+	// Start all needed scrapers. The supervisor restarts failed scrapers with their pairs resp. pools.
 	// @tradesChannelIn collects trades from the started scrapers.
-	tradesChannelIn := make(chan models.Trade)
+	tradesChannelIn := newTradesChannel()
+	supervisor := NewSupervisor(ctx, RestartPolicyFromEnv(), tradesChannelIn, wg)
 	for exchange := range exchangepairMap {
-		wg.Add(1)
-		go RunScraper(context.Background(), exchange, exchangepairMap[exchange], []models.Pool{}, tradesChannelIn, failoverChannel, wg)
+		supervisor.Start(exchange, exchangepairMap[exchange], []models.Pool{})
 	}
 	for exchange := range poolMap {
-		wg.Add(1)
-		go RunScraper(context.Background(), exchange, []models.ExchangePair{}, poolMap[exchange], tradesChannelIn, failoverChannel, wg)
+		supervisor.Start(exchange, []models.ExchangePair{}, poolMap[exchange])
 	}
 
-	// tradesblockMap maps an exchangpair identifier onto a TradesBlock.
-	// This also means that each value in the map consists of trades of only one exchangepair.
-	// We call these blocks "atomic" tradesblocks.
-	// TO DO: Make a dedicated type for atomic tradesblocks?
-	tradesblockMap := make(map[string]models.TradesBlock)
+	// If enabled, all received trades and all tradesblocks are written to the archive.
+	CollectTrades(ctx, tradesChannelIn, tradesblockChannel, triggerChannel, openArchive(), time.Now)
+	supervisor.Stop()
+}
 
-	go func() {
-		for {
-			select {
-			case trade := <-tradesChannelIn:
+// CollectTrades bundles the trades from @tradesChannel into atomic tradesblocks. On each trigger from
+// @triggerChannel, the tradesblocks are sent to @tradesblockChannel and written to @tradesArchive, if not nil.
+// On cancellation of @ctx, it sends a final block at time @now() and closes @tradesblockChannel.
+func CollectTrades(
+	ctx context.Context,
+	tradesChannel chan models.Trade,
+	tradesblockChannel chan map[string]models.TradesBlock,
+	triggerChannel chan time.Time,
+	tradesArchive *archive.Archive,
+	now func() time.Time,
+) {
+
+	// @buffer keeps the recent trades of each exchangepair, keyed by the exchangepair identifier.
+	// On each trigger, it yields a map from identifiers onto tradesblocks spanning the assets' lookback windows.
+	// Each value in the map consists of trades of only one exchangepair. We call these blocks "atomic" tradesblocks.
+	// TO DO: Make a dedicated type for atomic tradesblocks?
+	buffer := newTradesBuffer()
 
-				// Determine exchangepair and the corresponding identifier in order to assign the tradesBlockMap.
-				exchangepair := models.Pair{QuoteToken: trade.QuoteToken, BaseToken: trade.BaseToken}
-				exchangepairIdentifier := exchangepair.ExchangePairIdentifier(trade.Exchange.Name)
+	for {
+		select {
+		case trade := <-tradesChannel:
 
-				if _, ok := tradesblockMap[exchangepairIdentifier]; !ok {
-					tradesblockMap[exchangepairIdentifier] = models.TradesBlock{
-						Trades: []models.Trade{trade},
-						Pair:   exchangepair,
-					}
-				} else {
-					tradesblock := tradesblockMap[exchangepairIdentifier]
-					tradesblock.Trades = append(tradesblock.Trades, trade)
-					tradesblockMap[exchangepairIdentifier] = tradesblock
+			// Determine exchangepair and the corresponding identifier in order to assign the trade.
+			exchangepair := models.Pair{QuoteToken: trade.QuoteToken, BaseToken: trade.BaseToken}
+			exchangepairIdentifier := exchangepair.ExchangePairIdentifier(trade.Exchange.Name)
+			// Simulated swaps of different sizes go into separate tradesblocks.
+			if trade.Notional > 0 {
+				exchangepairIdentifier += "@" + strconv.FormatFloat(trade.Notional, 'f', -1, 64)
+			}
+			buffer.add(exchangepairIdentifier, exchangepair, trade)
+			if tradesArchive != nil {
+				if err := tradesArchive.WriteTrade(trade); err != nil {
+					log.Errorf("Collector - archive trade: %v.", err)
 				}
+			}
 
-			case timestamp := <-triggerChannel:
+		case timestamp := <-triggerChannel:
 
-				log.Debugf("Collector - triggered at %v.", timestamp)
-				for id := range tradesblockMap {
-					tb := tradesblockMap[id]
-					tb.EndTime = timestamp
-					tradesblockMap[id] = tb
-				}
+			log.Debugf("Collector - triggered at %v.", timestamp)
+			tradesblockMap := buffer.makeTradesblocks(timestamp)
+			archiveTradesblocks(tradesArchive, tradesblockMap)
+			tradesblockChannel <- tradesblockMap
+			log.Infof("Collector - number of tradesblocks: %v.", len(tradesblockMap))
 
+		case <-ctx.Done():
+			log.Info("Collector - Shutting down.")
+			if tradesblockMap := buffer.makeTradesblocks(now()); len(tradesblockMap) > 0 {
+				archiveTradesblocks(tradesArchive, tradesblockMap)
 				tradesblockChannel <- tradesblockMap
 				log.Infof("Collector - number of tradesblocks: %v.", len(tradesblockMap))
-
-				// Make a new tradesblockMap for the next trigger period.
-				tradesblockMap = make(map[string]models.TradesBlock)
-
-			case exchange := <-failoverChannel:
-				log.Debugf("Collector - Restart scraper for %s.", exchange)
-				wg.Add(1)
-				go RunScraper(context.Background(), exchange, exchangepairMap[exchange], []models.Pool{}, tradesChannelIn, failoverChannel, wg)
 			}
+			if tradesArchive != nil {
+				if err := tradesArchive.Close(); err != nil {
+					log.Errorf("Collector - close archive: %v.", err)
+				}
+			}
+			close(tradesblockChannel)
+			return
 		}
-	}()
+	}
+}
+
+// openArchive returns the archive given by the ARCHIVE_* environment variables or nil if it is disabled.
+func openArchive() *archive.Archive {
+	config, err := archive.ConfigFromEnv()
+	if err != nil {
+		log.Errorf("Collector - archive config: %v.", err)
+		return nil
+	}
+	if config.Dir == "" {
+		return nil
+	}
+	tradesArchive, err := archive.Open(config)
+	if err != nil {
+		log.Errorf("Collector - open archive in %s: %v.", config.Dir, err)
+		return nil
+	}
+	log.Infof("Collector - archive trades and tradesblocks in %s.", config.Dir)
+	return tradesArchive
+}
 
-	defer wg.Wait()
+// archiveTradesblocks writes all tradesblocks in @tradesblockMap to @tradesArchive, if enabled.
+func archiveTradesblocks(tradesArchive *archive.Archive, tradesblockMap map[string]models.TradesBlock) {
+	if tradesArchive == nil {
+		return
+	}
+	for id, tb := range tradesblockMap {
+		if err := tradesArchive.WriteTradesBlock(id, tb); err != nil {
+			log.Errorf("Collector - archive tradesblock %s: %v.", id, err)
+		}
+	}
+	if err := tradesArchive.Flush(); err != nil {
+		log.Errorf("Collector - flush archive: %v.", err)
+	}
 }
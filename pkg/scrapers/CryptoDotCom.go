@@ -2,6 +2,7 @@ package scrapers
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -61,13 +62,12 @@ var (
 	cryptodotcomWSBaseString = "wss://stream.crypto.com/v2/market"
 )
 
-func NewCryptodotcomScraper(ctx context.Context, pairs []models.ExchangePair, failoverChannel chan string, wg *sync.WaitGroup) Scraper {
-	defer wg.Done()
+func NewCryptodotcomScraper(ctx context.Context, pairs []models.ExchangePair) (Scraper, error) {
 	var lock sync.RWMutex
 	log.Info("Crypto.com - Started scraper.")
 
 	scraper := cryptodotcomScraper{
-		tradesChannel:       make(chan models.Trade),
+		tradesChannel:       newTradesChannel(),
 		subscribeChannel:    make(chan models.ExchangePair),
 		tickerPairMap:       models.MakeTickerPairMap(pairs),
 		lastTradeTimeMap:    make(map[string]time.Time),
@@ -81,9 +81,7 @@ func NewCryptodotcomScraper(ctx context.Context, pairs []models.ExchangePair, fa
 	var wsDialer ws.Dialer
 	wsClient, _, err := wsDialer.Dial(cryptodotcomWSBaseString, nil)
 	if err != nil {
-		log.Errorf("Crypto.com - Dial ws base string: %v.", err)
-		failoverChannel <- string(CRYPTODOTCOM_EXCHANGE)
-		return &scraper
+		return nil, fmt.Errorf("dial ws base string: %w", err)
 	}
 	scraper.wsClient = wsClient
 
@@ -111,7 +109,7 @@ func NewCryptodotcomScraper(ctx context.Context, pairs []models.ExchangePair, fa
 		go scraper.resubscribe(ctx, &lock)
 	}
 
-	return &scraper
+	return &scraper, nil
 }
 
 func (scraper *cryptodotcomScraper) Close(cancel context.CancelFunc) error {
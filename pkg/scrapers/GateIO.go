@@ -2,6 +2,7 @@ package scrapers
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -49,13 +50,12 @@ var (
 	_GateIOsocketurl string = "wss://api.gateio.ws/ws/v4/"
 )
 
-func NewGateIOScraper(ctx context.Context, pairs []models.ExchangePair, failoverChannel chan string, wg *sync.WaitGroup) Scraper {
-	defer wg.Done()
+func NewGateIOScraper(ctx context.Context, pairs []models.ExchangePair) (Scraper, error) {
 	var lock sync.RWMutex
 	log.Info("GateIO - Started scraper.")
 
 	scraper := gateIOScraper{
-		tradesChannel:    make(chan models.Trade),
+		tradesChannel:    newTradesChannel(),
 		subscribeChannel: make(chan models.ExchangePair),
 		tickerPairMap:    models.MakeTickerPairMap(pairs),
 		lastTradeTimeMap: make(map[string]time.Time),
@@ -67,9 +67,7 @@ func NewGateIOScraper(ctx context.Context, pairs []models.ExchangePair, failover
 	var wsDialer ws.Dialer
 	wsClient, _, err := wsDialer.Dial(_GateIOsocketurl, nil)
 	if err != nil {
-		log.Errorf("GateIO - Dial ws base string: %s." + err.Error())
-		failoverChannel <- string(GATEIO_EXCHANGE)
-		return &scraper
+		return nil, fmt.Errorf("dial ws base string: %w", err)
 	}
 	scraper.wsClient = wsClient
 
@@ -96,7 +94,7 @@ func NewGateIOScraper(ctx context.Context, pairs []models.ExchangePair, failover
 		go scraper.resubscribe(ctx, &lock)
 	}
 
-	return &scraper
+	return &scraper, nil
 
 }
 
@@ -2,6 +2,7 @@ package scrapers
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -54,13 +55,12 @@ var (
 	krakenWSBaseString = "wss://ws.kraken.com/v2"
 )
 
-func NewKrakenScraper(ctx context.Context, pairs []models.ExchangePair, failoverChannel chan string, wg *sync.WaitGroup) Scraper {
-	defer wg.Done()
+func NewKrakenScraper(ctx context.Context, pairs []models.ExchangePair) (Scraper, error) {
 	var lock sync.RWMutex
 	log.Info("Kraken - Started scraper.")
 
 	scraper := krakenScraper{
-		tradesChannel:    make(chan models.Trade),
+		tradesChannel:    newTradesChannel(),
 		subscribeChannel: make(chan models.ExchangePair),
 		tickerPairMap:    models.MakeTickerPairMap(pairs),
 		lastTradeTimeMap: make(map[string]time.Time),
@@ -72,9 +72,7 @@ func NewKrakenScraper(ctx context.Context, pairs []models.ExchangePair, failover
 	var wsDialer ws.Dialer
 	wsClient, _, err := wsDialer.Dial(krakenWSBaseString, nil)
 	if err != nil {
-		log.Errorf("Kraken - Dial ws base string: %v.", err)
-		failoverChannel <- string(KRAKEN_EXCHANGE)
-		return &scraper
+		return nil, fmt.Errorf("dial ws base string: %w", err)
 	}
 	scraper.wsClient = wsClient
 
@@ -103,7 +101,7 @@ func NewKrakenScraper(ctx context.Context, pairs []models.ExchangePair, failover
 		go scraper.resubscribe(ctx, &lock)
 	}
 
-	return &scraper
+	return &scraper, nil
 
 }
 
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -60,18 +61,17 @@ var (
 	kucoinPingIntervalFix = int64(10)
 )
 
-func NewKuCoinScraper(ctx context.Context, pairs []models.ExchangePair, failoverChannel chan string, wg *sync.WaitGroup) Scraper {
-	defer wg.Done()
+func NewKuCoinScraper(ctx context.Context, pairs []models.ExchangePair) (Scraper, error) {
 	var lock sync.RWMutex
 	log.Info("KuCoin - Started scraper.")
 
 	token, pingInterval, err := getPublicKuCoinToken(kucoinTokenURL)
 	if err != nil {
-		log.Errorf("KuCoin - getPublicKuCoinToken: %v.", err)
+		return nil, fmt.Errorf("get public token: %w", err)
 	}
 
 	scraper := kucoinScraper{
-		tradesChannel:    make(chan models.Trade),
+		tradesChannel:    newTradesChannel(),
 		subscribeChannel: make(chan models.ExchangePair),
 		tickerPairMap:    models.MakeTickerPairMap(pairs),
 		lastTradeTimeMap: make(map[string]time.Time),
@@ -83,9 +83,7 @@ func NewKuCoinScraper(ctx context.Context, pairs []models.ExchangePair, failover
 	var wsDialer ws.Dialer
 	wsClient, _, err := wsDialer.Dial(kucoinWSBaseString+"?token="+token, nil)
 	if err != nil {
-		log.Errorf("KuCoin - Dial ws base string: %v.", err)
-		failoverChannel <- string(KUCOIN_EXCHANGE)
-		return &scraper
+		return nil, fmt.Errorf("dial ws base string: %w", err)
 	}
 	scraper.wsClient = wsClient
 
@@ -113,7 +111,7 @@ func NewKuCoinScraper(ctx context.Context, pairs []models.ExchangePair, failover
 		go scraper.resubscribe(ctx, &lock)
 	}
 
-	return &scraper
+	return &scraper, nil
 
 }
 
@@ -1,28 +1,62 @@
 package scrapers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/diadata-org/decentral-feeder/pkg/contracts/uniswap"
 	"github.com/diadata-org/decentral-feeder/pkg/models"
 	simulation "github.com/diadata-org/decentral-feeder/pkg/scrapers/simulator"
 	"github.com/diadata-org/decentral-feeder/pkg/utils"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/tkanos/gonfig"
 )
 
 type SimulationScraper struct {
-	pools         []models.Pool
-	waitTime      int
-	restClient    *ethclient.Client
-	simulator     *simulation.Simulator
-	allowedTokens map[string]map[string]string
-	decimalCache  map[string]uint8
+	pools      []models.Pool
+	waitTime   int
+	restClient *ethclient.Client
+	simulator  *simulation.Simulator
+	config     SimulationConfig
+	metadata   *TokenMetadataService
+}
+
+// SimulationConfig is the configuration of the Simulation scraper as given in config/pools/Simulation.json.
+type SimulationConfig struct {
+	// FrequencySeconds is the polling interval for simulated swaps.
+	FrequencySeconds int
+	// WaitTimeMilliseconds is the delay between the simulations of subsequent tokens.
+	WaitTimeMilliseconds int
+	// Amount is the notional size of a simulated swap in units of the quote token. It is used for the spot feed.
+	Amount float64
+	// Amounts is a ladder of notional sizes. Each size is quoted separately and published as a size feed,
+	// for instance WETH/USD@100000, so that the price impact of large swaps can be observed.
+	Amounts []float64
+	// QuoteToken is the token that is swapped into the simulated tokens.
+	QuoteToken SimulationToken
+	Tokens     []SimulationToken
+	// Blockchain is the name of the chain the simulations run on. Defaults to Ethereum.
+	Blockchain string
+	// QuoterAddress and IntermediateToken are optional and override the UniswapV3 QuoterV2
+	// and the routing token (usually the wrapped native token) of the chain's default deployment.
+	QuoterAddress     string
+	IntermediateToken string
+}
+
+// SimulationToken is a token that can be simulated. Pools of the Simulation exchange refer to it by its symbol.
+// Amount, Amounts and QuoteToken are optional and override the global values of SimulationConfig.
+type SimulationToken struct {
+	Symbol     string
+	Address    string
+	Amount     float64
+	Amounts    []float64
+	QuoteToken *SimulationToken
 }
 
 type SwapEvents struct {
@@ -40,104 +74,139 @@ type SimulationResponse struct {
 	TokenOut    string       `json:"tokenOutStr"`
 }
 
-func NewSimulationScraper(pools []models.Pool, tradesChannel chan models.Trade, wg *sync.WaitGroup) {
+// NewSimulationScraper quotes all @pools every FrequencySeconds until @ctx is cancelled. If the scraper cannot be
+// initialized, Simulation is sent to @failoverChannel.
+func NewSimulationScraper(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade, failoverChannel chan string) {
 	var (
 		err     error
 		scraper SimulationScraper
 	)
-	scraper.restClient, err = ethclient.Dial(utils.Getenv(UNISWAPV2_EXCHANGE+"_URI_REST", restDial))
+	scraper.restClient, err = ethclient.Dial(utils.Getenv(Simulation+"_URI_REST", utils.Getenv(UNISWAPV2_EXCHANGE+"_URI_REST", restDial)))
 	if err != nil {
-		log.Error("init rest client: ", err)
+		log.Errorf("Simulation - init rest client: %v.", err)
+		failoverChannel <- Simulation
+		return
 	}
 	scraper.pools = pools
-	// scraper.tradeSimulationRPC = "http://localhost:8085/tradesimulator/symbol" //?symbol=UNI&blocknumber=20333049
-	scraper.simulator = simulation.New(scraper.restClient, log)
-	scraper.initTokens()
-	scraper.decimalCache = make(map[string]uint8)
+	scraper.config, err = GetSimulationConfig()
+	if err != nil {
+		log.Errorf("Simulation - GetSimulationConfig: %v.", err)
+		failoverChannel <- Simulation
+		return
+	}
+	scraper.simulator, err = simulation.New(
+		ctx,
+		scraper.restClient,
+		common.HexToAddress(scraper.config.QuoterAddress),
+		common.HexToAddress(scraper.config.IntermediateToken),
+		log,
+	)
+	if err != nil {
+		log.Errorf("Simulation - init simulator: %v.", err)
+		failoverChannel <- Simulation
+		return
+	}
+	scraper.waitTime = scraper.config.WaitTimeMilliseconds
+	scraper.metadata, err = NewTokenMetadataService(scraper.restClient, scraper.config.Blockchain)
+	if err != nil {
+		log.Errorf("Simulation - init token metadata service: %v.", err)
+		failoverChannel <- Simulation
+		return
+	}
+	scraper.prefetchTokens(ctx)
 
 	log.Info("Started Simulation scraper.")
 
-	ticker := time.NewTicker(30 * time.Second)
-	go func() {
-		for {
-			select {
+	ticker := time.NewTicker(time.Duration(scraper.config.FrequencySeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
 
-			case <-ticker.C:
-				log.Info("RUN Simulation scraper.")
+		case <-ticker.C:
+			log.Info("RUN Simulation scraper.")
 
-				go scraper.mainLoop(pools, tradesChannel)
-			}
+			go scraper.mainLoop(ctx, pools, tradesChannel)
+		case <-ctx.Done():
+			log.Info("Simulation - stopped.")
+			return
 		}
-	}()
+	}
 
 }
 
-func (scraper *SimulationScraper) mainLoop(pools []models.Pool, tradesChannel chan models.Trade) {
+func (scraper *SimulationScraper) mainLoop(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade) {
 
 	// wait for all pairs have added into s.PairScrapers
 	time.Sleep(4 * time.Second)
 
+	// All tokens of a round are quoted at the same block so that results are consistent and reproducible.
+	blockNumber, blockTime, err := scraper.getRoundBlock(ctx)
+	if err != nil {
+		log.Errorf("Simulation - get block: %v.", err)
+		return
+	}
+	log.Infof("Simulation - quote round at block %v from %v.", blockNumber, blockTime)
+
 	var wg sync.WaitGroup
 	for _, pool := range pools {
+		if ctx.Err() != nil {
+			break
+		}
 		time.Sleep(time.Duration(scraper.waitTime) * time.Millisecond)
 		wg.Add(1)
 		go func(symbol string, w *sync.WaitGroup) {
 			defer w.Done()
 
-			tokens := scraper.allowedTokens[symbol]
-
-			tokenInDecimal, ok := scraper.decimalCache[tokens["tokenInStr"]]
+			token, ok := scraper.getToken(symbol)
 			if !ok {
-				var err error
-				tokenInDecimal, err = scraper.GetDecimals(common.HexToAddress(tokens["tokenInStr"]))
-				if err != nil {
-					log.Errorf("error getting decimal tokenInStr of symbol %s err: %v", symbol, err)
-					return
-				}
-				scraper.decimalCache[tokens["tokenInStr"]] = tokenInDecimal
+				log.Errorf("Simulation - token %s not found in config.", symbol)
+				return
 			}
-
-			tokenOutDecimal, ok := scraper.decimalCache[tokens["tokenOutStr"]]
-			if !ok {
-				var err error
-				tokenOutDecimal, err = scraper.GetDecimals(common.HexToAddress(tokens["tokenOutStr"]))
-				if err != nil {
-					log.Errorf("error getting decimal tokenOutStr of symbol %s err: %v", symbol, err)
-					return
-				}
-				scraper.decimalCache[tokens["tokenOutStr"]] = tokenOutDecimal
+			quoteToken := scraper.config.QuoteToken
+			if token.QuoteToken != nil {
+				quoteToken = *token.QuoteToken
 			}
-			token0 := models.Asset{
-				Symbol:     "USDC",
-				Name:       "USDC",
-				Address:    tokens["tokenInStr"],
-				Decimals:   tokenInDecimal,
-				Blockchain: utils.ETHEREUM,
+			amount := scraper.config.Amount
+			if token.Amount > 0 {
+				amount = token.Amount
 			}
 
-			token1 := models.Asset{
-				Address:    tokens["tokenOutStr"],
-				Symbol:     symbol,
-				Name:       symbol,
-				Decimals:   tokenOutDecimal,
-				Blockchain: utils.ETHEREUM,
+			token0, err := scraper.getAsset(ctx, quoteToken)
+			if err != nil {
+				log.Errorf("Simulation - quote token of symbol %s: %v", symbol, err)
+				return
 			}
-			price, err := scraper.simulator.Execute(token1, token0)
+			token1, err := scraper.getAsset(ctx, token)
 			if err != nil {
-				log.Errorf("error getting price of %s ", symbol)
+				log.Errorf("Simulation - token of symbol %s: %v", symbol, err)
 				return
 			}
 
-			f, _ := strconv.ParseFloat(price, 64)
-			t := models.Trade{
-				Price:      1000 / f,
-				Volume:     float64(1),
-				BaseToken:  token0,
-				QuoteToken: token1,
-				Time:       time.Now(),
-				Exchange:   models.Exchange{Name: Simulation, Blockchain: utils.ETHEREUM},
+			spotTrade, err := scraper.simulateTrade(token1, token0, amount, blockNumber, blockTime)
+			if err != nil {
+				log.Errorf("Simulation - quote %v %s into %s: %v.", amount, token0.Symbol, symbol, err)
+				return
+			}
+			sendTrade(ctx, Simulation, tradesChannel, spotTrade)
+
+			amounts := scraper.config.Amounts
+			if len(token.Amounts) > 0 {
+				amounts = token.Amounts
+			}
+			for _, size := range amounts {
+				// The spot size is already published as the spot feed.
+				if size == amount {
+					continue
+				}
+				t, err := scraper.simulateTrade(token1, token0, size, blockNumber, blockTime)
+				if err != nil {
+					log.Errorf("Simulation - quote %v %s into %s: %v.", size, token0.Symbol, symbol, err)
+					continue
+				}
+				t.Notional = size
+				simulationPriceImpact.WithLabelValues(symbol, strconv.FormatFloat(size, 'f', -1, 64)).Set(t.Price/spotTrade.Price - 1)
+				sendTrade(ctx, Simulation, tradesChannel, t)
 			}
-			tradesChannel <- t
 
 		}(pool.Address, &wg)
 	}
@@ -145,6 +214,143 @@ func (scraper *SimulationScraper) mainLoop(pools []models.Pool, tradesChannel ch
 
 }
 
+// getRoundBlock returns number and timestamp of the block a simulation round is pinned to. This is the latest block
+// unless Simulation_BLOCK_NUMBER is set, which allows to re-run a round against an archive node.
+func (scraper *SimulationScraper) getRoundBlock(ctx context.Context) (uint64, time.Time, error) {
+	var number *big.Int
+	if pinned := utils.Getenv(Simulation+"_BLOCK_NUMBER", ""); pinned != "" {
+		n, err := strconv.ParseUint(pinned, 10, 64)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("parse %s_BLOCK_NUMBER: %w", Simulation, err)
+		}
+		number = new(big.Int).SetUint64(n)
+	}
+	header, err := scraper.restClient.HeaderByNumber(ctx, number)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return header.Number.Uint64(), time.Unix(int64(header.Time), 0), nil
+}
+
+// simulateTrade returns a trade with the effective price of swapping @amount units of @baseToken into @quoteToken
+// at block @blockNumber. The trade time is the timestamp @blockTime of that block, so that pinned rounds line up
+// with lookback windows and replays.
+func (scraper *SimulationScraper) simulateTrade(quoteToken models.Asset, baseToken models.Asset, amount float64, blockNumber uint64, blockTime time.Time) (models.Trade, error) {
+	if amount <= 0 {
+		return models.Trade{}, fmt.Errorf("amount %v is not positive", amount)
+	}
+	quote, err := scraper.simulator.Execute(quoteToken, baseToken, strconv.FormatFloat(amount, 'f', -1, 64), blockNumber)
+	if err != nil {
+		return models.Trade{}, err
+	}
+	log.Debugf("Simulation - %v %s into %s: path %v with fees %v, gas estimate %v.", amount, baseToken.Symbol, quoteToken.Symbol, quote.Path, quote.Fees, quote.GasEstimate)
+
+	f, _ := strconv.ParseFloat(simulation.CurrencyToString(quote.AmountOut, int(quoteToken.Decimals)), 64)
+	if f == 0 {
+		return models.Trade{}, errors.New("zero output")
+	}
+	return models.Trade{
+		Price: amount / f,
+		// Simulated swaps have unit volume, so that the size of a quote does not weigh in volume-weighted filters.
+		Volume:      float64(1),
+		BaseToken:   baseToken,
+		QuoteToken:  quoteToken,
+		Time:        blockTime,
+		Exchange:    models.Exchange{Name: Simulation, Blockchain: scraper.config.Blockchain},
+		BlockNumber: quote.BlockNumber,
+	}, nil
+}
+
+// GetSimulationConfig returns the configuration of the Simulation scraper from config/pools/Simulation.json.
+func GetSimulationConfig() (config SimulationConfig, err error) {
+	path := utils.GetPath("pools/", Simulation)
+	err = gonfig.GetConf(path, &config)
+	if err != nil {
+		return
+	}
+	err = config.validate()
+	if config.Blockchain == "" {
+		config.Blockchain = utils.ETHEREUM
+	}
+	return
+}
+
+// validate returns an error if the interval or a notional size of @config is not positive.
+// The sizes of a token may be omitted, in which case the global sizes apply.
+func (config SimulationConfig) validate() error {
+	if config.FrequencySeconds <= 0 {
+		return errors.New("FrequencySeconds must be positive")
+	}
+	if config.Amount <= 0 {
+		return errors.New("Amount must be positive")
+	}
+	for _, amount := range config.Amounts {
+		if amount <= 0 {
+			return fmt.Errorf("Amounts must be positive, got %v", amount)
+		}
+	}
+	for _, token := range config.Tokens {
+		if token.Amount < 0 {
+			return fmt.Errorf("Amount of %s must be positive, got %v", token.Symbol, token.Amount)
+		}
+		for _, amount := range token.Amounts {
+			if amount <= 0 {
+				return fmt.Errorf("Amounts of %s must be positive, got %v", token.Symbol, amount)
+			}
+		}
+	}
+	return nil
+}
+
+// getToken returns the configured token for @symbol. Pools can also refer to a token by its address.
+func (scraper *SimulationScraper) getToken(symbol string) (SimulationToken, bool) {
+	for _, token := range scraper.config.Tokens {
+		if token.Symbol == symbol {
+			return token, true
+		}
+	}
+	if common.IsHexAddress(symbol) {
+		return SimulationToken{Address: symbol}, true
+	}
+	return SimulationToken{}, false
+}
+
+// prefetchTokens fetches the metadata of all configured tokens in one batch.
+func (scraper *SimulationScraper) prefetchTokens(ctx context.Context) {
+	addresses := []common.Address{common.HexToAddress(scraper.config.QuoteToken.Address)}
+	for _, token := range scraper.config.Tokens {
+		addresses = append(addresses, common.HexToAddress(token.Address))
+		if token.QuoteToken != nil {
+			addresses = append(addresses, common.HexToAddress(token.QuoteToken.Address))
+		}
+	}
+	if _, err := scraper.metadata.GetTokens(ctx, addresses); err != nil {
+		log.Warnf("Simulation - prefetch token metadata: %v.", err)
+	}
+}
+
+// getAsset returns the full asset information for @token. Decimals and missing symbols are fetched on-chain.
+func (scraper *SimulationScraper) getAsset(ctx context.Context, token SimulationToken) (asset models.Asset, err error) {
+	metadata, err := scraper.metadata.GetToken(ctx, common.HexToAddress(token.Address))
+	if err != nil {
+		return
+	}
+
+	symbol := token.Symbol
+	if symbol == "" {
+		symbol = metadata.Symbol
+	}
+
+	asset = models.Asset{
+		Address:    token.Address,
+		Symbol:     symbol,
+		Name:       symbol,
+		Decimals:   metadata.Decimals,
+		Blockchain: scraper.config.Blockchain,
+	}
+	return
+}
+
 // func (scraper *SimulationScraper) getSimulatedResult(symbol string, blocknumber uint64) (sr SimulationResponse, err error) {
 
 // 	url := scraper.tradeSimulationRPC + "?symbol=" + symbol + "&blocknumber=" + strconv.Itoa(int(blocknumber))
@@ -171,19 +377,6 @@ func (scraper *SimulationScraper) mainLoop(pools []models.Pool, tradesChannel ch
 // 	return
 // }
 
-func (scraper *SimulationScraper) GetDecimals(tokenAddress common.Address) (decimals uint8, err error) {
-
-	var contract *uniswap.IERC20Caller
-	contract, err = uniswap.NewIERC20Caller(tokenAddress, scraper.restClient)
-	if err != nil {
-		log.Error(err)
-		return
-	}
-	decimals, err = contract.Decimals(&bind.CallOpts{})
-
-	return
-}
-
 func getSimulationSwapData(events []SwapEvents, tokenInDecimal, tokenOutDecimal uint8) (float64, float64) {
 	if len(events) == 0 {
 		return 0, 0
@@ -220,52 +413,3 @@ func getSimulationSwapData(events []SwapEvents, tokenInDecimal, tokenOutDecimal
 
 	return price, 1000
 }
-
-func (scraper *SimulationScraper) initTokens() {
-	scraper.allowedTokens = make(map[string]map[string]string)
-	var wethConfig = make(map[string]string)
-	wethConfig["tokenInStr"] = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
-	wethConfig["tokenOutStr"] = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
-	wethConfig["amountStr"] = "100000000"
-	wethConfig["recipient"] = "0xD6153F5af5679a75cC85D8974463545181f48772"
-	scraper.allowedTokens["WETH"] = wethConfig
-
-	var wbtcConfig = make(map[string]string)
-	wbtcConfig["tokenInStr"] = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
-	wbtcConfig["amountStr"] = "100000000"
-	wbtcConfig["recipient"] = "0xD6153F5af5679a75cC85D8974463545181f48772"
-
-	wbtcConfig["tokenOutStr"] = "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599"
-	scraper.allowedTokens["WBTC"] = wbtcConfig
-
-	var uniConfig = make(map[string]string)
-	uniConfig["tokenInStr"] = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
-	uniConfig["amountStr"] = "1000000000"
-	uniConfig["recipient"] = "0xD6153F5af5679a75cC85D8974463545181f48772"
-
-	uniConfig["tokenOutStr"] = "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"
-	scraper.allowedTokens["UNI"] = uniConfig
-
-	var pepeConfig = make(map[string]string)
-	pepeConfig["tokenInStr"] = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
-	pepeConfig["amountStr"] = "100000000"
-	pepeConfig["recipient"] = "0xD6153F5af5679a75cC85D8974463545181f48772"
-
-	pepeConfig["tokenOutStr"] = "0x6982508145454ce325ddbe47a25d4ec3d2311933"
-	scraper.allowedTokens["PEPE"] = pepeConfig
-
-	var diaConfig = make(map[string]string)
-	diaConfig["tokenInStr"] = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
-	diaConfig["amountStr"] = "1000000000"
-	diaConfig["recipient"] = "0xD6153F5af5679a75cC85D8974463545181f48772"
-	diaConfig["tokenOutStr"] = "0x84cA8bc7997272c7CfB4D0Cd3D55cd942B3c9419"
-	scraper.allowedTokens["DIA"] = diaConfig
-
-	var ustB = make(map[string]string)
-	ustB["tokenInStr"] = "0x83feDBc0B85c6e29B589aA6BdefB1Cc581935ECD"
-	ustB["amountStr"] = "1000000000"
-	ustB["recipient"] = "0xC6B3AaaAbf2f6eD6cF7fdFFfb0DaC45E10c4A5B3"
-	ustB["tokenOutStr"] = "0xAEC9e50e3397f9ddC635C6c429C8C7eca418a143"
-	scraper.allowedTokens["USTB"] = ustB
-
-}
@@ -102,3 +102,31 @@ func Test1(t *testing.T) {
 	}
 
 }
+
+func TestSimulationConfigValidate(t *testing.T) {
+	valid := SimulationConfig{
+		FrequencySeconds: 30,
+		Amount:           1000,
+		Amounts:          []float64{10000},
+		Tokens:           []SimulationToken{{Symbol: "WETH"}, {Symbol: "WBTC", Amount: 500, Amounts: []float64{5000}}},
+	}
+	if err := valid.validate(); err != nil {
+		t.Errorf("validate() of valid config: %v", err)
+	}
+
+	invalid := []func(config *SimulationConfig){
+		func(config *SimulationConfig) { config.Amount = 0 },
+		func(config *SimulationConfig) { config.Amounts = []float64{0} },
+		func(config *SimulationConfig) { config.Tokens = []SimulationToken{{Symbol: "WETH", Amount: -1}} },
+		func(config *SimulationConfig) {
+			config.Tokens = []SimulationToken{{Symbol: "WETH", Amounts: []float64{-1}}}
+		},
+	}
+	for i, modify := range invalid {
+		config := valid
+		modify(&config)
+		if err := config.validate(); err == nil {
+			t.Errorf("validate() of invalid config %d returned no error", i)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/daoleno/uniswapv3-sdk/examples/contract"
+	"github.com/diadata-org/decentral-feeder/pkg/contracts/uniswap"
+	"github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	// q112 is the fixed point resolution of UniswapV2 cumulative prices.
+	q112 = new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 112))
+	// uint256Modulus is used for the overflow-safe difference of cumulative prices.
+	uint256Modulus = new(big.Int).Lsh(big.NewInt(1), 256)
+)
+
+// UniswapTWAPScraper periodically computes time-weighted average prices of UniswapV2 pairs
+// or UniswapV3 pools from on-chain accumulators instead of listening to swaps.
+// Each TWAP is emitted as a synthetic trade of the exchange UniswapV2TWAP resp. UniswapV3TWAP.
+type UniswapTWAPScraper struct {
+	exchange         string
+	restClient       *ethclient.Client
+	metadata         *TokenMetadataService
+	windowSeconds    uint32
+	frequencySeconds int
+	// pairs maps a pool address onto its tokens.
+	pairs map[common.Address]UniswapPair
+	// observations maps a UniswapV2 pair address onto its cumulative price snapshots, oldest first.
+	// UniswapV2 pairs only store the latest accumulator, so the scraper has to keep the history itself.
+	observations map[common.Address][]twapObservation
+}
+
+type twapObservation struct {
+	timestamp  uint64
+	cumulative *big.Int
+}
+
+// NewUniswapV2TWAPScraper emits TWAPs of UniswapV2 pairs computed from price0CumulativeLast/price1CumulativeLast.
+// The first TWAP of a pair is emitted once the scraper has observed it for a full window.
+func NewUniswapV2TWAPScraper(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade, failoverChannel chan string) {
+	newUniswapTWAPScraper(ctx, UNISWAPV2_TWAP_EXCHANGE, UNISWAPV2_EXCHANGE, pools, tradesChannel, failoverChannel)
+}
+
+// NewUniswapV3TWAPScraper emits TWAPs of UniswapV3 pools computed from the pools' observe().
+func NewUniswapV3TWAPScraper(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade, failoverChannel chan string) {
+	newUniswapTWAPScraper(ctx, UNISWAPV3_TWAP_EXCHANGE, UNISWAPV2_EXCHANGE, pools, tradesChannel, failoverChannel)
+}
+
+// newUniswapTWAPScraper runs until @ctx is cancelled. If the scraper cannot be initialized, @exchange is sent
+// to @failoverChannel.
+func newUniswapTWAPScraper(ctx context.Context, exchange string, fallbackExchange string, pools []models.Pool, tradesChannel chan models.Trade, failoverChannel chan string) {
+	var (
+		err     error
+		scraper UniswapTWAPScraper
+	)
+	scraper.exchange = exchange
+	scraper.restClient, err = ethclient.Dial(utils.Getenv(exchange+"_URI_REST", utils.Getenv(fallbackExchange+"_URI_REST", restDial)))
+	if err != nil {
+		log.Errorf("%s - init rest client: %v.", exchange, err)
+		failoverChannel <- exchange
+		return
+	}
+	scraper.metadata, err = NewTokenMetadataService(scraper.restClient, Exchanges[exchange].Blockchain)
+	if err != nil {
+		log.Errorf("%s - init token metadata service: %v.", exchange, err)
+		failoverChannel <- exchange
+		return
+	}
+
+	windowSeconds, err := strconv.ParseUint(utils.Getenv(exchange+"_WINDOW_SECONDS", "1800"), 10, 32)
+	if err != nil || windowSeconds == 0 {
+		log.Errorf("%s - parse %s_WINDOW_SECONDS: %v.", exchange, exchange, err)
+		windowSeconds = 1800
+	}
+	scraper.windowSeconds = uint32(windowSeconds)
+	scraper.frequencySeconds, err = strconv.Atoi(utils.Getenv(exchange+"_FREQUENCY_SECONDS", "60"))
+	if err != nil || scraper.frequencySeconds <= 0 {
+		log.Errorf("%s - parse %s_FREQUENCY_SECONDS: %v.", exchange, exchange, err)
+		scraper.frequencySeconds = 60
+	}
+	scraper.observations = make(map[common.Address][]twapObservation)
+
+	scraper.pairs, err = scraper.getPairs(ctx, pools)
+	if err != nil {
+		log.Errorf("%s - get pool tokens: %v.", exchange, err)
+		failoverChannel <- exchange
+		return
+	}
+
+	log.Infof("Started %s scraper with window of %v seconds.", exchange, scraper.windowSeconds)
+	ticker := time.NewTicker(time.Duration(scraper.frequencySeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		scraper.mainLoop(ctx, pools, tradesChannel)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Infof("%s - stopped.", exchange)
+			return
+		}
+	}
+}
+
+// mainLoop computes the TWAPs of all @pools at the latest block.
+func (scraper *UniswapTWAPScraper) mainLoop(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade) {
+	header, err := scraper.restClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Errorf("%s - get latest header: %v.", scraper.exchange, err)
+		return
+	}
+	opts := &bind.CallOpts{BlockNumber: header.Number, Context: ctx}
+
+	for _, pool := range pools {
+		address := common.HexToAddress(pool.Address)
+		pair, ok := scraper.pairs[address]
+		if !ok {
+			continue
+		}
+
+		var price float64
+		switch scraper.exchange {
+		case UNISWAPV2_TWAP_EXCHANGE:
+			price, err = scraper.getUniswapV2TWAP(opts, header.Time, pair, pool.Order)
+		case UNISWAPV3_TWAP_EXCHANGE:
+			price, err = scraper.getUniswapV3TWAP(opts, pair, pool.Order)
+		}
+		if err != nil {
+			log.Warnf("%s - TWAP of %s: %v.", scraper.exchange, pool.Address, err)
+			continue
+		}
+		if price == 0 {
+			continue
+		}
+
+		quoteToken, baseToken := pair.Token0, pair.Token1
+		if pool.Order == 1 {
+			quoteToken, baseToken = pair.Token1, pair.Token0
+		}
+		t := models.Trade{
+			Price:       price,
+			QuoteToken:  uniToken2Asset(quoteToken),
+			BaseToken:   uniToken2Asset(baseToken),
+			Time:        time.Unix(int64(header.Time), 0),
+			Exchange:    Exchanges[scraper.exchange],
+			PoolAddress: address.Hex(),
+			BlockNumber: header.Number.Uint64(),
+		}
+		log.Debugf("%s - TWAP of %s over %v seconds: %v.", scraper.exchange, pair.ForeignName, scraper.windowSeconds, price)
+		sendTrade(ctx, scraper.exchange, tradesChannel, t)
+	}
+}
+
+// getPairs returns the tokens of all @pools. UniswapV3 pools expose token0/token1 like UniswapV2 pairs.
+func (scraper *UniswapTWAPScraper) getPairs(ctx context.Context, pools []models.Pool) (map[common.Address]UniswapPair, error) {
+	pairs := make(map[common.Address]UniswapPair)
+	var addresses []common.Address
+	for _, pool := range pools {
+		addresses = append(addresses, common.HexToAddress(pool.Address))
+	}
+	pairTokens, err := scraper.metadata.GetPairTokens(ctx, addresses)
+	if err != nil {
+		return pairs, err
+	}
+	var tokenAddresses []common.Address
+	for _, tokens := range pairTokens {
+		tokenAddresses = append(tokenAddresses, tokens[0], tokens[1])
+	}
+	tokens, err := scraper.metadata.GetTokens(ctx, tokenAddresses)
+	if err != nil {
+		return pairs, err
+	}
+	for address, t := range pairTokens {
+		metadata0, ok0 := tokens[t[0]]
+		metadata1, ok1 := tokens[t[1]]
+		if !ok0 || !ok1 {
+			log.Warnf("%s - missing token metadata for pool %s.", scraper.exchange, address.Hex())
+			continue
+		}
+		pairs[address] = UniswapPair{
+			Address:     address,
+			ForeignName: metadata0.Symbol + "-" + metadata1.Symbol,
+			Token0:      UniswapToken{Address: t[0], Symbol: metadata0.Symbol, Name: metadata0.Name, Decimals: metadata0.Decimals},
+			Token1:      UniswapToken{Address: t[1], Symbol: metadata1.Symbol, Name: metadata1.Name, Decimals: metadata1.Decimals},
+		}
+	}
+	return pairs, nil
+}
+
+// getUniswapV2TWAP stores the current cumulative price of @pair and returns the TWAP over the configured window.
+func (scraper *UniswapTWAPScraper) getUniswapV2TWAP(opts *bind.CallOpts, blockTimestamp uint64, pair UniswapPair, order uint8) (float64, error) {
+	pairContract, err := uniswap.NewUniswapV2PairCaller(pair.Address, scraper.restClient)
+	if err != nil {
+		return 0, err
+	}
+	rawCaller := &uniswap.UniswapV2PairCallerRaw{Contract: pairContract}
+
+	var out []interface{}
+	err = rawCaller.Call(opts, &out, "getReserves")
+	if err != nil {
+		return 0, err
+	}
+	if len(out) < 3 {
+		return 0, errors.New("unexpected output of getReserves")
+	}
+	reserve0, ok0 := out[0].(*big.Int)
+	reserve1, ok1 := out[1].(*big.Int)
+	blockTimestampLast, ok2 := out[2].(uint32)
+	if !ok0 || !ok1 || !ok2 {
+		return 0, errors.New("unexpected output of getReserves")
+	}
+
+	method := "price0CumulativeLast"
+	reserveIn, reserveOut := reserve0, reserve1
+	if order == 1 {
+		method = "price1CumulativeLast"
+		reserveIn, reserveOut = reserve1, reserve0
+	}
+	out = nil
+	err = rawCaller.Call(opts, &out, method)
+	if err != nil {
+		return 0, err
+	}
+	if len(out) < 1 {
+		return 0, errors.New("unexpected output of " + method)
+	}
+	cumulativeLast, ok := out[0].(*big.Int)
+	if !ok {
+		return 0, errors.New("unexpected output of " + method)
+	}
+	cumulative := uniswapV2CurrentCumulativePrice(cumulativeLast, reserveIn, reserveOut, blockTimestampLast, blockTimestamp)
+
+	observations := append(scraper.observations[pair.Address], twapObservation{timestamp: blockTimestamp, cumulative: cumulative})
+	// Keep the latest observation that is at least one window old as start of the TWAP.
+	windowStart := blockTimestamp - uint64(scraper.windowSeconds)
+	for len(observations) > 1 && observations[1].timestamp <= windowStart {
+		observations = observations[1:]
+	}
+	scraper.observations[pair.Address] = observations
+
+	start := observations[0]
+	if start.timestamp > windowStart {
+		log.Debugf("%s - not enough history for %s yet.", scraper.exchange, pair.ForeignName)
+		return 0, nil
+	}
+
+	decimalsIn, decimalsOut := pair.Token0.Decimals, pair.Token1.Decimals
+	if order == 1 {
+		decimalsIn, decimalsOut = decimalsOut, decimalsIn
+	}
+	price := uniswapV2TWAP(start.cumulative, cumulative, blockTimestamp-start.timestamp)
+	return price * math.Pow10(int(decimalsIn)-int(decimalsOut)), nil
+}
+
+// getUniswapV3TWAP returns the TWAP of @pair over the configured window from the pool's observations.
+func (scraper *UniswapTWAPScraper) getUniswapV3TWAP(opts *bind.CallOpts, pair UniswapPair, order uint8) (float64, error) {
+	poolContract, err := contract.NewUniswapv3PoolCaller(pair.Address, scraper.restClient)
+	if err != nil {
+		return 0, err
+	}
+	observation, err := poolContract.Observe(opts, []uint32{scraper.windowSeconds, 0})
+	if err != nil {
+		// observe reverts if the pool's observation cardinality does not cover the window.
+		return 0, err
+	}
+	if len(observation.TickCumulatives) != 2 {
+		return 0, errors.New("unexpected output of observe")
+	}
+	tick := uniswapV3TWAPTick(observation.TickCumulatives[0], observation.TickCumulatives[1], scraper.windowSeconds)
+
+	decimalsIn, decimalsOut := pair.Token0.Decimals, pair.Token1.Decimals
+	if order == 1 {
+		tick = -tick
+		decimalsIn, decimalsOut = decimalsOut, decimalsIn
+	}
+	return math.Pow(1.0001, float64(tick)) * math.Pow10(int(decimalsIn)-int(decimalsOut)), nil
+}
+
+// uniswapV2CurrentCumulativePrice adds the price accumulated since the pair's last update to @cumulativeLast,
+// as done by UniswapV2OracleLibrary.currentCumulativePrices. Timestamps are taken modulo 2^32.
+func uniswapV2CurrentCumulativePrice(cumulativeLast, reserveIn, reserveOut *big.Int, blockTimestampLast uint32, blockTimestamp uint64) *big.Int {
+	cumulative := new(big.Int).Set(cumulativeLast)
+	elapsed := uint32(blockTimestamp) - blockTimestampLast
+	if elapsed == 0 || reserveIn.Sign() == 0 {
+		return cumulative
+	}
+	price := new(big.Int).Lsh(reserveOut, 112)
+	price.Div(price, reserveIn)
+	cumulative.Add(cumulative, price.Mul(price, big.NewInt(int64(elapsed))))
+	return cumulative.Mod(cumulative, uint256Modulus)
+}
+
+// uniswapV2TWAP returns the average price between two cumulative prices taken @elapsed seconds apart.
+// The price is in raw token units, i.e. not adjusted for decimals.
+func uniswapV2TWAP(cumulativeStart, cumulativeEnd *big.Int, elapsed uint64) float64 {
+	if elapsed == 0 {
+		return 0
+	}
+	// Cumulative prices are designed to overflow, so the difference is taken modulo 2^256.
+	diff := new(big.Int).Sub(cumulativeEnd, cumulativeStart)
+	diff.Mod(diff, uint256Modulus)
+	diff.Div(diff, new(big.Int).SetUint64(elapsed))
+	price, _ := new(big.Float).Quo(new(big.Float).SetInt(diff), q112).Float64()
+	return price
+}
+
+// uniswapV3TWAPTick returns the arithmetic mean tick between two tick cumulatives taken @window seconds apart,
+// rounded towards negative infinity as in the OracleLibrary.
+func uniswapV3TWAPTick(tickCumulativeStart, tickCumulativeEnd *big.Int, window uint32) int64 {
+	delta := new(big.Int).Sub(tickCumulativeEnd, tickCumulativeStart)
+	w := big.NewInt(int64(window))
+	tick, remainder := new(big.Int).QuoRem(delta, w, new(big.Int))
+	if delta.Sign() < 0 && remainder.Sign() != 0 {
+		tick.Sub(tick, big.NewInt(1))
+	}
+	return tick.Int64()
+}
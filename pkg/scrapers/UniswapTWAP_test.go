@@ -0,0 +1,83 @@
+package scrapers
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestUniswapV2TWAP(t *testing.T) {
+	q := func(x int64) *big.Int {
+		return new(big.Int).Lsh(big.NewInt(x), 112)
+	}
+	// Start of the window shortly before the accumulator overflows.
+	overflowStart := new(big.Int).Sub(uint256Modulus, q(5))
+
+	cases := []struct {
+		cumulativeStart *big.Int
+		cumulativeEnd   *big.Int
+		elapsed         uint64
+		price           float64
+	}{
+		{big.NewInt(0), q(20), 10, 2},
+		{q(100), q(130), 60, 0.5},
+		{overflowStart, q(15), 10, 2},
+		{q(1), q(2), 0, 0},
+	}
+
+	for i, c := range cases {
+		price := uniswapV2TWAP(c.cumulativeStart, c.cumulativeEnd, c.elapsed)
+		if price != c.price {
+			t.Errorf("uniswapV2TWAP was incorrect, got: %v, expected: %v for set: %d", price, c.price, i)
+		}
+	}
+}
+
+func TestUniswapV2CurrentCumulativePrice(t *testing.T) {
+	q := func(x int64) *big.Int {
+		return new(big.Int).Lsh(big.NewInt(x), 112)
+	}
+	cases := []struct {
+		cumulativeLast     *big.Int
+		reserveIn          *big.Int
+		reserveOut         *big.Int
+		blockTimestampLast uint32
+		blockTimestamp     uint64
+		cumulative         *big.Int
+	}{
+		// Pair updated in the current block.
+		{q(7), big.NewInt(4), big.NewInt(2), 100, 100, q(7)},
+		// Price of 0.5 accumulated over 10 seconds since the last update.
+		{q(7), big.NewInt(4), big.NewInt(2), 100, 110, q(12)},
+		// Block timestamp wrapped around 2^32.
+		{q(0), big.NewInt(1), big.NewInt(3), 4294967295, 1 << 32, q(3)},
+	}
+
+	for i, c := range cases {
+		cumulative := uniswapV2CurrentCumulativePrice(c.cumulativeLast, c.reserveIn, c.reserveOut, c.blockTimestampLast, c.blockTimestamp)
+		if cumulative.Cmp(c.cumulative) != 0 {
+			t.Errorf("uniswapV2CurrentCumulativePrice was incorrect, got: %v, expected: %v for set: %d", cumulative, c.cumulative, i)
+		}
+	}
+}
+
+func TestUniswapV3TWAPTick(t *testing.T) {
+	cases := []struct {
+		tickCumulativeStart int64
+		tickCumulativeEnd   int64
+		window              uint32
+		tick                int64
+	}{
+		{0, 1000, 10, 100},
+		{500, 1509, 10, 100},
+		{0, -1000, 10, -100},
+		// Negative ticks are rounded towards negative infinity.
+		{0, -1001, 10, -101},
+	}
+
+	for i, c := range cases {
+		tick := uniswapV3TWAPTick(big.NewInt(c.tickCumulativeStart), big.NewInt(c.tickCumulativeEnd), c.window)
+		if tick != c.tick {
+			t.Errorf("uniswapV3TWAPTick was incorrect, got: %v, expected: %v for set: %d", tick, c.tick, i)
+		}
+	}
+}
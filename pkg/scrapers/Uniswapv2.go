@@ -1,8 +1,12 @@
 package scrapers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,14 +16,19 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 var (
 	restDial = ""
 	wsDial   = ""
-	poolMap  = make(map[string]UniswapPair)
+	// Failed Sync subscriptions are renewed after @uniswapV2ResubscribeSeconds.
+	uniswapV2ResubscribeSeconds = 5
 )
 
+// maxReserveSyncs is the number of Sync events kept per pool for looking up the reserves before a swap.
+const maxReserveSyncs = 64
+
 type UniswapToken struct {
 	Address  common.Address
 	Symbol   string
@@ -44,252 +53,676 @@ type UniswapSwap struct {
 	Amount1Out float64
 }
 
+// reserveSync holds the normalized reserves of a pool after the Sync event with @logIndex in block @blockNumber.
+type reserveSync struct {
+	blockNumber uint64
+	logIndex    uint
+	reserve0    float64
+	reserve1    float64
+}
+
+// before returns true if @sync was emitted before the log with @logIndex in block @blockNumber.
+func (sync reserveSync) before(blockNumber uint64, logIndex uint) bool {
+	return sync.blockNumber < blockNumber || (sync.blockNumber == blockNumber && sync.logIndex < logIndex)
+}
+
 type UniswapV2Scraper struct {
 	pools      []models.Pool
 	wsClient   *ethclient.Client
 	restClient *ethclient.Client
+	metadata   *TokenMetadataService
 	waitTime   int
+	// poolMap maps a pool address onto its UniswapPair. It is built before any goroutine of the scraper starts
+	// and is read-only afterwards.
+	poolMap map[string]UniswapPair
+	// reservesMap maps a pool address onto the pool with its latest reserves in Assetvolumes.
+	reservesMap map[string]models.Pool
+	// syncs maps a pool address onto the reserves after its latest Sync events.
+	syncs        map[string][]reserveSync
+	reservesLock sync.RWMutex
+	// usdPriceMap maps a token address onto its USD price. It is refreshed every @reservesPollSeconds.
+	usdPriceMap         map[common.Address]float64
+	reservesPollSeconds int
+	// Swaps selling more than the share @maxPriceImpact of the pool's reserves are discarded.
+	maxPriceImpact float64
+	// The swaps of a block are checked after a swap from a later block arrives, or after @blockFlushSeconds.
+	blockFlushSeconds int
+}
+
+// NewUniswapV2Scraper scrapes swaps of @pools until @ctx is cancelled. If the scraper cannot be initialized or a swap
+// subscription fails, UNISWAPV2_EXCHANGE is sent to @failoverChannel.
+func NewUniswapV2Scraper(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade, failoverChannel chan string) {
+	scraper, err := newUniswapV2Scraper(ctx, pools)
+	if err == nil {
+		log.Info("Started UniswapV2 scraper.")
+		err = scraper.run(ctx, pools, tradesChannel)
+	}
+	if err != nil {
+		log.Errorf("UniswapV2 - %v.", err)
+		failoverChannel <- UNISWAPV2_EXCHANGE
+	}
 }
 
-func NewUniswapV2Scraper(pools []models.Pool, tradesChannel chan models.Trade, wg *sync.WaitGroup) {
+// newUniswapV2Scraper connects to the node and fetches the pairs of @pools and of the pools along their routes.
+func newUniswapV2Scraper(ctx context.Context, pools []models.Pool) (*UniswapV2Scraper, error) {
 	var err error
-	var scraper UniswapV2Scraper
-	log.Info("Started UniswapV2 scraper.")
+	scraper := &UniswapV2Scraper{}
 
 	scraper.restClient, err = ethclient.Dial(utils.Getenv(UNISWAPV2_EXCHANGE+"_URI_REST", restDial))
 	if err != nil {
-		log.Error("UniswapV2 - init rest client: ", err)
+		return nil, fmt.Errorf("init rest client: %w", err)
 	}
 	scraper.wsClient, err = ethclient.Dial(utils.Getenv(UNISWAPV2_EXCHANGE+"_URI_WS", wsDial))
 	if err != nil {
-		log.Error("UniswapV2 - init ws client: ", err)
+		return nil, fmt.Errorf("init ws client: %w", err)
+	}
+	scraper.metadata, err = NewTokenMetadataService(scraper.restClient, utils.ETHEREUM)
+	if err != nil {
+		return nil, fmt.Errorf("init token metadata service: %w", err)
 	}
 
 	// TO DO: Import through env var.
 	scraper.waitTime = 500
+	scraper.reservesPollSeconds, err = strconv.Atoi(utils.Getenv(UNISWAPV2_EXCHANGE+"_RESERVES_POLL_SECONDS", "60"))
+	if err != nil {
+		log.Errorf("UniswapV2 - parse %s_RESERVES_POLL_SECONDS: %v.", UNISWAPV2_EXCHANGE, err)
+		scraper.reservesPollSeconds = 60
+	}
+	scraper.maxPriceImpact, err = strconv.ParseFloat(utils.Getenv(UNISWAPV2_EXCHANGE+"_MAX_PRICE_IMPACT", "0.1"), 64)
+	if err != nil {
+		log.Errorf("UniswapV2 - parse %s_MAX_PRICE_IMPACT: %v.", UNISWAPV2_EXCHANGE, err)
+		scraper.maxPriceImpact = 0.1
+	}
+	scraper.blockFlushSeconds, err = strconv.Atoi(utils.Getenv(UNISWAPV2_EXCHANGE+"_BLOCK_FLUSH_SECONDS", "15"))
+	if err != nil {
+		log.Errorf("UniswapV2 - parse %s_BLOCK_FLUSH_SECONDS: %v.", UNISWAPV2_EXCHANGE, err)
+		scraper.blockFlushSeconds = 15
+	}
+	scraper.reservesMap = make(map[string]models.Pool)
+	scraper.syncs = make(map[string][]reserveSync)
+	scraper.usdPriceMap = make(map[common.Address]float64)
+
 	// Fetch all pool with given liquidity threshold from database.
-	poolMap, err = scraper.makeUniPoolMap(pools)
+	// Pools along price conversion routes are needed for their reserves as well.
+	scraper.poolMap, err = scraper.makeUniPoolMap(ctx, withRoutePools(pools))
 	if err != nil {
-		log.Error("UniswapV2 - build poolMap: ", err)
+		return nil, fmt.Errorf("build poolMap: %w", err)
 	}
+	return scraper, nil
+}
 
-	go scraper.mainLoop(pools, tradesChannel)
+// run scrapes swaps of @pools until @ctx is cancelled or a swap subscription fails.
+func (scraper *UniswapV2Scraper) run(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go scraper.pollReserves(ctx, withRoutePools(pools))
+	return scraper.mainLoop(ctx, pools, tradesChannel)
 }
 
-// runs in a goroutine until s is closed
-func (scraper *UniswapV2Scraper) mainLoop(pools []models.Pool, tradesChannel chan models.Trade) {
+// mainLoop listens to all @pools and returns the first error of a pool. The caller cancels @ctx to stop the others.
+func (scraper *UniswapV2Scraper) mainLoop(ctx context.Context, pools []models.Pool, tradesChannel chan models.Trade) error {
 
 	// wait for all pairs have added into s.PairScrapers
 	time.Sleep(4 * time.Second)
 
-	var wg sync.WaitGroup
+	for _, pool := range withRoutePools(pools) {
+		scraper.watchReserves(ctx, common.HexToAddress(pool.Address))
+	}
+	errs := make(chan error, len(pools))
 	for _, pool := range pools {
 		time.Sleep(time.Duration(scraper.waitTime) * time.Millisecond)
-		wg.Add(1)
-		go func(address common.Address, w *sync.WaitGroup) {
-			defer w.Done()
-			scraper.ListenToPair(address, tradesChannel)
-		}(common.HexToAddress(pool.Address), &wg)
+		go func(pool models.Pool) {
+			errs <- scraper.ListenToPair(ctx, pool, tradesChannel)
+		}(pool)
+	}
+	for range pools {
+		if err := <-errs; err != nil {
+			return err
+		}
 	}
-	wg.Wait()
+	return nil
 
 }
 
+// withRoutePools returns @pools together with all pools along their price conversion routes.
+// Each pool address is contained only once.
+func withRoutePools(pools []models.Pool) (allPools []models.Pool) {
+	addresses := make(map[common.Address]struct{})
+	for _, pool := range pools {
+		if _, ok := addresses[common.HexToAddress(pool.Address)]; !ok {
+			addresses[common.HexToAddress(pool.Address)] = struct{}{}
+			allPools = append(allPools, pool)
+		}
+	}
+	for _, pool := range pools {
+		for _, address := range pool.Route {
+			if _, ok := addresses[common.HexToAddress(address)]; ok {
+				continue
+			}
+			addresses[common.HexToAddress(address)] = struct{}{}
+			allPools = append(allPools, models.Pool{
+				Exchange:   pool.Exchange,
+				Blockchain: pool.Blockchain,
+				Address:    address,
+			})
+		}
+	}
+	return
+}
+
 // makeUniPoolMap returns a map with pool addresses as keys and the underlying UniswapPair as values.
-func (scraper *UniswapV2Scraper) makeUniPoolMap(pools []models.Pool) (map[string]UniswapPair, error) {
+func (scraper *UniswapV2Scraper) makeUniPoolMap(ctx context.Context, pools []models.Pool) (map[string]UniswapPair, error) {
 	pm := make(map[string]UniswapPair)
-	var err error
 
+	var addresses []common.Address
 	for _, p := range pools {
-		pm[p.Address], err = scraper.GetPairByAddress(common.HexToAddress(p.Address))
-		if err != nil {
-			log.Error("UniswapV2 - GetPairByAddress for ", p.Address)
-			return pm, err
+		addresses = append(addresses, common.HexToAddress(p.Address))
+	}
+	pairs, err := scraper.GetPairsByAddress(ctx, addresses)
+	if err != nil {
+		log.Error("UniswapV2 - GetPairsByAddress: ", err)
+		return pm, err
+	}
+	for _, address := range addresses {
+		pair, ok := pairs[address]
+		if !ok {
+			log.Error("UniswapV2 - GetPairsByAddress for ", address.Hex())
+			continue
 		}
+		pm[address.Hex()] = pair
 	}
 	return pm, nil
 }
 
 // ListenToPair subscribes to a uniswap pool.
-// If @byAddress is true, it listens by pool address, otherwise by index.
-func (scraper *UniswapV2Scraper) ListenToPair(address common.Address, tradesChannel chan models.Trade) {
-	var err error
+// The priced asset is determined by @pool.Order. If @pool.Route is given, prices are converted along the route.
+// It returns nil on cancellation of @ctx and an error if the swap subscription fails.
+func (scraper *UniswapV2Scraper) ListenToPair(ctx context.Context, pool models.Pool, tradesChannel chan models.Trade) error {
+	address := common.HexToAddress(pool.Address)
 
-	// Relevant pool info is retrieved from @poolMap.
-	pair := poolMap[address.Hex()]
+	// Relevant pool info is retrieved from @scraper.poolMap.
+	pair := scraper.poolMap[address.Hex()]
 
-	sink, err := scraper.GetSwapsChannel(address)
+	sink, sub, err := scraper.GetSwapsChannel(ctx, address)
 	if err != nil {
-		log.Error("UniswapV2 - error fetching swaps channel: ", err)
+		return fmt.Errorf("swaps channel of %s: %w", address.Hex(), err)
 	}
+	defer sub.Unsubscribe()
+
+	// Swaps pass the trade-quality stage before they are sent to @tradesChannel.
+	dexFilter := newDEXTradeFilter(
+		UNISWAPV2_EXCHANGE,
+		address.Hex(),
+		scraper.maxPriceImpact,
+		func(swap dexSwap) float64 { return scraper.reserveInBefore(address, swap) },
+		time.Duration(scraper.blockFlushSeconds)*time.Second,
+		tradesChannel,
+	)
+	go dexFilter.run(ctx)
+
+	for {
+		var (
+			rawSwap *uniswap.UniswapV2PairSwap
+			ok      bool
+		)
+		select {
+		case rawSwap, ok = <-sink:
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+			return fmt.Errorf("swap subscription of %s: %w", address.Hex(), err)
+		case <-ctx.Done():
+			return nil
+		}
+		if ok {
+			// Swaps from reorged blocks are discarded.
+			if rawSwap.Raw.Removed {
+				continue
+			}
+			swap, err := scraper.normalizeUniswapSwap(*rawSwap, pair)
+			if err != nil {
+				log.Error("UniswapV2 - error normalizing swap: ", err)
+			}
+			price, volume := getSwapData(swap, pool.Order)
+			quoteToken, baseToken := pair.Token0, pair.Token1
+			if pool.Order == 1 {
+				quoteToken, baseToken = pair.Token1, pair.Token0
+			}
+			liquidityUSD := scraper.getLiquidityUSD(address)
 
-	go func() {
-		for {
-			rawSwap, ok := <-sink
-			if ok {
-				swap, err := scraper.normalizeUniswapSwap(*rawSwap, pair)
+			if len(pool.Route) > 0 {
+				price, baseToken, liquidityUSD, err = scraper.convertAlongRoute(price, baseToken, liquidityUSD, pool.Route)
 				if err != nil {
-					log.Error("UniswapV2 - error normalizing swap: ", err)
-				}
-				price, volume := getSwapData(swap)
-				token0 := models.Asset{
-					Address:    pair.Token0.Address.Hex(),
-					Symbol:     pair.Token0.Symbol,
-					Name:       pair.Token0.Name,
-					Decimals:   pair.Token0.Decimals,
-					Blockchain: utils.ETHEREUM,
-				}
-				token1 := models.Asset{
-					Address:    pair.Token1.Address.Hex(),
-					Symbol:     pair.Token1.Symbol,
-					Name:       pair.Token1.Name,
-					Decimals:   pair.Token1.Decimals,
-					Blockchain: utils.ETHEREUM,
-				}
-				t := models.Trade{
-					Price:          price,
-					Volume:         volume,
-					BaseToken:      token1,
-					QuoteToken:     token0,
-					Time:           time.Unix(swap.Timestamp, 0),
-					PoolAddress:    rawSwap.Raw.Address.Hex(),
-					ForeignTradeID: swap.ID,
-					Exchange:       models.Exchange{Name: UNISWAPV2_EXCHANGE, Blockchain: utils.ETHEREUM},
+					log.Errorf("UniswapV2 - convert price of pool %s along route: %v.", pool.Address, err)
+					continue
 				}
+			}
 
-				// log.Info("tx hash: ", swap.ID)
-				// log.Infof(
-				// 	"Got trade at time %v - symbol: %s, pair: %s, price: %v, volume:%v",
-				// 	t.Time,
-				// 	t.QuoteToken.Symbol,
-				// 	t.QuoteToken.Symbol+"-"+t.BaseToken.Symbol,
-				// 	t.Price,
-				// 	t.Volume,
-				// )
-				tradesChannel <- t
+			t := models.Trade{
+				Price:          price,
+				Volume:         volume,
+				BaseToken:      uniToken2Asset(baseToken),
+				QuoteToken:     uniToken2Asset(quoteToken),
+				Time:           time.Unix(swap.Timestamp, 0),
+				PoolAddress:    rawSwap.Raw.Address.Hex(),
+				ForeignTradeID: swap.ID,
+				Exchange:       models.Exchange{Name: UNISWAPV2_EXCHANGE, Blockchain: utils.ETHEREUM},
+				LiquidityUSD:   liquidityUSD,
+				BlockNumber:    rawSwap.Raw.BlockNumber,
+			}
 
+			// log.Info("tx hash: ", swap.ID)
+			// log.Infof(
+			// 	"Got trade at time %v - symbol: %s, pair: %s, price: %v, volume:%v",
+			// 	t.Time,
+			// 	t.QuoteToken.Symbol,
+			// 	t.QuoteToken.Symbol+"-"+t.BaseToken.Symbol,
+			// 	t.Price,
+			// 	t.Volume,
+			// )
+			select {
+			case dexFilter.swapsChannel <- scraper.makeDEXSwap(t, swap, *rawSwap):
+			case <-ctx.Done():
+				return nil
 			}
+
 		}
-	}()
+	}
+}
+
+// makeDEXSwap returns the trade @t together with the information needed for the trade-quality stage.
+func (scraper *UniswapV2Scraper) makeDEXSwap(t models.Trade, swap UniswapSwap, rawSwap uniswap.UniswapV2PairSwap) dexSwap {
+	ds := dexSwap{
+		trade:      t,
+		txIndex:    rawSwap.Raw.TxIndex,
+		logIndex:   rawSwap.Raw.Index,
+		sender:     rawSwap.Sender,
+		recipient:  rawSwap.To,
+		zeroForOne: swap.Amount0In > 0,
+	}
+	ds.amountIn = swap.Amount1In
+	if ds.zeroForOne {
+		ds.amountIn = swap.Amount0In
+	}
+	return ds
+}
+
+// reserveInBefore returns the normalized reserve of the token sold into the pool with @address right before @swap.
+// It is taken from the last Sync event preceding @swap. As a swap emits its Sync event right before the Swap event,
+// the amount sold is subtracted if that Sync belongs to @swap itself. Without a known Sync before @swap, the latest
+// polled reserves are used.
+func (scraper *UniswapV2Scraper) reserveInBefore(address common.Address, swap dexSwap) float64 {
+	scraper.reservesLock.RLock()
+	defer scraper.reservesLock.RUnlock()
+
+	var (
+		last  reserveSync
+		found bool
+	)
+	for _, sync := range scraper.syncs[address.Hex()] {
+		if !sync.before(swap.trade.BlockNumber, swap.logIndex) {
+			continue
+		}
+		if !found || last.before(sync.blockNumber, sync.logIndex) {
+			last, found = sync, true
+		}
+	}
+	if !found {
+		inIndex := uint8(1)
+		if swap.zeroForOne {
+			inIndex = 0
+		}
+		for _, av := range scraper.reservesMap[address.Hex()].Assetvolumes {
+			if av.Index == inIndex {
+				return av.Volume
+			}
+		}
+		return 0
+	}
+
+	reserveIn := last.reserve1
+	if swap.zeroForOne {
+		reserveIn = last.reserve0
+	}
+	if last.blockNumber == swap.trade.BlockNumber && last.logIndex+1 == swap.logIndex {
+		reserveIn -= swap.amountIn
+	}
+	return reserveIn
+}
+
+// convertAlongRoute converts @price, denominated in @baseToken, along the pools with addresses @route using
+// their spot prices, for instance from WETH into USDC for a route consisting of a WETH/USDC pool.
+// It returns the converted price together with the new base token. The returned liquidity is the
+// minimum of @liquidityUSD and the liquidity of all route pools.
+func (scraper *UniswapV2Scraper) convertAlongRoute(
+	price float64,
+	baseToken UniswapToken,
+	liquidityUSD float64,
+	route []string,
+) (float64, UniswapToken, float64, error) {
+	for _, hop := range route {
+		address := common.HexToAddress(hop)
+		pair, ok := scraper.poolMap[address.Hex()]
+		if !ok {
+			return 0, baseToken, 0, fmt.Errorf("route pool %s not found", hop)
+		}
+		pool := scraper.getPoolReserves(address)
+
+		var (
+			hopPrice float64
+			err      error
+		)
+		switch baseToken.Address {
+		case pair.Token0.Address:
+			hopPrice, err = pool.SpotPrice(0, 1)
+			baseToken = pair.Token1
+		case pair.Token1.Address:
+			hopPrice, err = pool.SpotPrice(1, 0)
+			baseToken = pair.Token0
+		default:
+			return 0, baseToken, 0, fmt.Errorf("route pool %s does not contain %s", hop, baseToken.Symbol)
+		}
+		if err != nil {
+			return 0, baseToken, 0, fmt.Errorf("spot price of route pool %s: %v", hop, err)
+		}
+		price *= hopPrice
+
+		if hopLiquidity := pool.LiquidityUSD(); hopLiquidity > 0 && (liquidityUSD == 0 || hopLiquidity < liquidityUSD) {
+			liquidityUSD = hopLiquidity
+		}
+	}
+	return price, baseToken, liquidityUSD, nil
 }
 
 // GetSwapsChannel returns a channel for swaps of the pair with address @pairAddress
-func (scraper *UniswapV2Scraper) GetSwapsChannel(pairAddress common.Address) (chan *uniswap.UniswapV2PairSwap, error) {
+// together with the underlying subscription.
+func (scraper *UniswapV2Scraper) GetSwapsChannel(ctx context.Context, pairAddress common.Address) (chan *uniswap.UniswapV2PairSwap, event.Subscription, error) {
 
 	sink := make(chan *uniswap.UniswapV2PairSwap)
-	var pairFiltererContract *uniswap.UniswapV2PairFilterer
 	pairFiltererContract, err := uniswap.NewUniswapV2PairFilterer(pairAddress, scraper.wsClient)
 	if err != nil {
-		log.Error("UniswapV2 - pair filterer: ", err)
+		return sink, nil, err
 	}
 
-	_, err = pairFiltererContract.WatchSwap(&bind.WatchOpts{}, sink, []common.Address{}, []common.Address{})
+	sub, err := pairFiltererContract.WatchSwap(&bind.WatchOpts{Context: ctx}, sink, []common.Address{}, []common.Address{})
 	if err != nil {
-		log.Error("UniswapV2 - error in get swaps channel: ", err)
+		return sink, nil, err
 	}
-
-	return sink, nil
+	return sink, sub, nil
 }
 
-// GetPairByAddress returns the UniswapPair with pair address @pairAddress
-func (scraper *UniswapV2Scraper) GetPairByAddress(pairAddress common.Address) (pair UniswapPair, err error) {
-	connection := scraper.restClient
-	var pairContract *uniswap.IUniswapV2PairCaller
-	pairContract, err = uniswap.NewIUniswapV2PairCaller(pairAddress, connection)
+// GetSyncChannel returns a channel for reserve updates of the pair with address @pairAddress
+// together with the underlying subscription.
+func (scraper *UniswapV2Scraper) GetSyncChannel(ctx context.Context, pairAddress common.Address) (chan *uniswap.UniswapV2PairSync, event.Subscription, error) {
+
+	sink := make(chan *uniswap.UniswapV2PairSync)
+	pairFiltererContract, err := uniswap.NewUniswapV2PairFilterer(pairAddress, scraper.wsClient)
 	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
-		return UniswapPair{}, err
+		return sink, nil, err
 	}
 
-	// Getting tokens from pair ---------------------
-	address0, _ := pairContract.Token0(&bind.CallOpts{})
-	address1, _ := pairContract.Token1(&bind.CallOpts{})
-	var token0Contract *uniswap.IERC20Caller
-	var token1Contract *uniswap.IERC20Caller
-	token0Contract, err = uniswap.NewIERC20Caller(address0, connection)
+	sub, err := pairFiltererContract.WatchSync(&bind.WatchOpts{Context: ctx}, sink)
 	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
+		return sink, nil, err
 	}
-	token1Contract, err = uniswap.NewIERC20Caller(address1, connection)
+	return sink, sub, nil
+}
+
+// watchReserves keeps the reserves of the pool with @address up to date by listening to its Sync events.
+// A failed subscription is renewed after @uniswapV2ResubscribeSeconds. In between, pollReserves keeps the reserves current.
+func (scraper *UniswapV2Scraper) watchReserves(ctx context.Context, address common.Address) {
+	go func() {
+		for {
+			err := scraper.listenToSyncs(ctx, address)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("UniswapV2 - sync subscription for %s: %v. Resubscribe in %v seconds.", address.Hex(), err, uniswapV2ResubscribeSeconds)
+			select {
+			case <-time.After(time.Duration(uniswapV2ResubscribeSeconds) * time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// listenToSyncs applies the Sync events of the pool with @address to its reserves until the subscription fails
+// or @ctx is cancelled.
+func (scraper *UniswapV2Scraper) listenToSyncs(ctx context.Context, address common.Address) error {
+	sink, sub, err := scraper.GetSyncChannel(ctx, address)
 	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
+		return err
 	}
-	symbol0, err := token0Contract.Symbol(&bind.CallOpts{})
-	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case rawSync := <-sink:
+			// Reserves of Syncs from reorged blocks are discarded.
+			if !rawSync.Raw.Removed {
+				scraper.updateReserves(address, rawSync.Reserve0, rawSync.Reserve1)
+				scraper.recordSync(address, rawSync.Raw.BlockNumber, rawSync.Raw.Index, rawSync.Reserve0, rawSync.Reserve1)
+			}
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
-	symbol1, err := token1Contract.Symbol(&bind.CallOpts{})
-	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
+}
+
+// pollReserves periodically refreshes reserves and USD prices for all @pools.
+// This way, reserves are available before the first Sync event and USD values follow the market.
+func (scraper *UniswapV2Scraper) pollReserves(ctx context.Context, pools []models.Pool) {
+	ticker := time.NewTicker(time.Duration(scraper.reservesPollSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		scraper.updateUSDPrices()
+		for _, pool := range pools {
+			address := common.HexToAddress(pool.Address)
+			reserve0, reserve1, err := scraper.GetReserves(address)
+			if err != nil {
+				log.Errorf("UniswapV2 - GetReserves for %s: %v.", pool.Address, err)
+				continue
+			}
+			scraper.updateReserves(address, reserve0, reserve1)
+
+			p := scraper.getPoolReserves(address)
+			spotPrice, err := p.SpotPrice(pool.Order, 1-pool.Order)
+			if err != nil {
+				log.Warnf("UniswapV2 - spot price for %s: %v.", pool.Address, err)
+				continue
+			}
+			log.Debugf("UniswapV2 - pool %s: spot price %v, liquidity %v USD.", pool.Address, spotPrice, p.LiquidityUSD())
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
 	}
-	decimals0, err := scraper.GetDecimals(address0)
-	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
-		return UniswapPair{}, err
+}
+
+// updateUSDPrices fetches the USD prices of all assets in the scraper's pools.
+func (scraper *UniswapV2Scraper) updateUSDPrices() {
+	tokens := make(map[common.Address]struct{})
+	for _, pair := range scraper.poolMap {
+		tokens[pair.Token0.Address] = struct{}{}
+		tokens[pair.Token1.Address] = struct{}{}
 	}
-	decimals1, err := scraper.GetDecimals(address1)
-	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
-		return UniswapPair{}, err
+	for token := range tokens {
+		price, err := utils.GetAssetQuotation(utils.ETHEREUM, token.Hex())
+		if err != nil {
+			log.Debugf("UniswapV2 - GetAssetQuotation for %s: %v.", token.Hex(), err)
+			continue
+		}
+		scraper.reservesLock.Lock()
+		scraper.usdPriceMap[token] = price
+		scraper.reservesLock.Unlock()
 	}
+}
 
-	name0, err := scraper.GetName(address0)
-	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
-		return UniswapPair{}, err
-	}
-	name1, err := scraper.GetName(address1)
-	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
-		return UniswapPair{}, err
+// updateReserves sets the normalized reserves and their USD values for the pool with @address.
+func (scraper *UniswapV2Scraper) updateReserves(address common.Address, reserve0 *big.Int, reserve1 *big.Int) {
+	pair, ok := scraper.poolMap[address.Hex()]
+	if !ok {
+		return
 	}
-	token0 := UniswapToken{
-		Address:  address0,
-		Symbol:   symbol0,
-		Decimals: decimals0,
-		Name:     name0,
+	volume0, volume1 := normalizeReserves(pair, reserve0, reserve1)
+
+	scraper.reservesLock.Lock()
+	defer scraper.reservesLock.Unlock()
+	scraper.reservesMap[address.Hex()] = models.Pool{
+		Exchange:   models.Exchange{Name: UNISWAPV2_EXCHANGE, Blockchain: utils.ETHEREUM},
+		Blockchain: models.Blockchain{Name: utils.ETHEREUM},
+		Address:    address.Hex(),
+		Assetvolumes: []models.AssetVolume{
+			{
+				Asset:     uniToken2Asset(pair.Token0),
+				Volume:    volume0,
+				Index:     0,
+				VolumeUSD: volume0 * scraper.usdPriceMap[pair.Token0.Address],
+			},
+			{
+				Asset:     uniToken2Asset(pair.Token1),
+				Volume:    volume1,
+				Index:     1,
+				VolumeUSD: volume1 * scraper.usdPriceMap[pair.Token1.Address],
+			},
+		},
+		Time: time.Now(),
 	}
-	token1 := UniswapToken{
-		Address:  address1,
-		Symbol:   symbol1,
-		Decimals: decimals1,
-		Name:     name1,
+}
+
+// recordSync keeps the reserves of the pool with @address after the Sync event with @logIndex in block @blockNumber.
+// Only the latest @maxReserveSyncs Sync events of each pool are kept.
+func (scraper *UniswapV2Scraper) recordSync(address common.Address, blockNumber uint64, logIndex uint, reserve0 *big.Int, reserve1 *big.Int) {
+	pair, ok := scraper.poolMap[address.Hex()]
+	if !ok {
+		return
 	}
-	foreignName := symbol0 + "-" + symbol1
-	pair = UniswapPair{
-		ForeignName: foreignName,
-		Address:     pairAddress,
-		Token0:      token0,
-		Token1:      token1,
+	volume0, volume1 := normalizeReserves(pair, reserve0, reserve1)
+
+	scraper.reservesLock.Lock()
+	defer scraper.reservesLock.Unlock()
+	syncs := append(scraper.syncs[address.Hex()], reserveSync{blockNumber: blockNumber, logIndex: logIndex, reserve0: volume0, reserve1: volume1})
+	if len(syncs) > maxReserveSyncs {
+		syncs = syncs[len(syncs)-maxReserveSyncs:]
 	}
-	return pair, nil
+	scraper.syncs[address.Hex()] = syncs
+}
+
+// normalizeReserves returns @reserve0 and @reserve1 of @pair in units of the respective token.
+func normalizeReserves(pair UniswapPair, reserve0 *big.Int, reserve1 *big.Int) (float64, float64) {
+	volume0, _ := new(big.Float).Quo(new(big.Float).SetInt(reserve0), new(big.Float).SetFloat64(math.Pow10(int(pair.Token0.Decimals)))).Float64()
+	volume1, _ := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetFloat64(math.Pow10(int(pair.Token1.Decimals)))).Float64()
+	return volume0, volume1
 }
 
-// GetDecimals returns the decimals of the token with address @tokenAddress
-func (scraper *UniswapV2Scraper) GetDecimals(tokenAddress common.Address) (decimals uint8, err error) {
+// getPoolReserves returns the pool with address @address including its latest reserves.
+func (scraper *UniswapV2Scraper) getPoolReserves(address common.Address) models.Pool {
+	scraper.reservesLock.RLock()
+	defer scraper.reservesLock.RUnlock()
+	return scraper.reservesMap[address.Hex()]
+}
+
+// getLiquidityUSD returns the USD value of the reserves of the pool with @address.
+func (scraper *UniswapV2Scraper) getLiquidityUSD(address common.Address) float64 {
+	pool := scraper.getPoolReserves(address)
+	return pool.LiquidityUSD()
+}
 
-	var contract *uniswap.IERC20Caller
-	contract, err = uniswap.NewIERC20Caller(tokenAddress, scraper.restClient)
+// GetReserves returns the reserves of the pair with address @pairAddress.
+// The raw contract call is used, as the generated binding does not check for errors before unpacking.
+func (scraper *UniswapV2Scraper) GetReserves(pairAddress common.Address) (reserve0 *big.Int, reserve1 *big.Int, err error) {
+	pairContract, err := uniswap.NewUniswapV2PairCaller(pairAddress, scraper.restClient)
 	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
 		return
 	}
-	decimals, err = contract.Decimals(&bind.CallOpts{})
+	var out []interface{}
+	rawCaller := &uniswap.UniswapV2PairCallerRaw{Contract: pairContract}
+	err = rawCaller.Call(&bind.CallOpts{}, &out, "getReserves")
+	if err != nil {
+		return
+	}
+	if len(out) < 2 {
+		err = errors.New("unexpected output of getReserves")
+		return
+	}
+	reserve0, ok0 := out[0].(*big.Int)
+	reserve1, ok1 := out[1].(*big.Int)
+	if !ok0 || !ok1 {
+		return nil, nil, errors.New("unexpected output of getReserves")
+	}
+	return
+}
 
+// GetPairByAddress returns the UniswapPair with pair address @pairAddress
+func (scraper *UniswapV2Scraper) GetPairByAddress(ctx context.Context, pairAddress common.Address) (pair UniswapPair, err error) {
+	pairs, err := scraper.GetPairsByAddress(ctx, []common.Address{pairAddress})
+	if err != nil {
+		return
+	}
+	pair, ok := pairs[pairAddress]
+	if !ok {
+		err = errors.New("no pair found for " + pairAddress.Hex())
+	}
 	return
 }
 
-func (scraper *UniswapV2Scraper) GetName(tokenAddress common.Address) (name string, err error) {
+// GetPairsByAddress returns the UniswapPairs with addresses @pairAddresses. Pair tokens and token metadata
+// are fetched in batches. Pairs whose tokens cannot be resolved are missing in the returned map.
+func (scraper *UniswapV2Scraper) GetPairsByAddress(ctx context.Context, pairAddresses []common.Address) (map[common.Address]UniswapPair, error) {
+	pairs := make(map[common.Address]UniswapPair)
+	if scraper.metadata == nil {
+		return pairs, errors.New("no token metadata service")
+	}
 
-	var contract *uniswap.IERC20Caller
-	contract, err = uniswap.NewIERC20Caller(tokenAddress, scraper.restClient)
+	pairTokens, err := scraper.metadata.GetPairTokens(ctx, pairAddresses)
 	if err != nil {
-		log.Error("UniswapV2 - " + err.Error())
-		return
+		return pairs, err
+	}
+	var tokenAddresses []common.Address
+	for _, tokens := range pairTokens {
+		tokenAddresses = append(tokenAddresses, tokens[0], tokens[1])
+	}
+	tokens, err := scraper.metadata.GetTokens(ctx, tokenAddresses)
+	if err != nil {
+		return pairs, err
 	}
-	name, err = contract.Name(&bind.CallOpts{})
 
-	return
+	for pairAddress, addresses := range pairTokens {
+		metadata0, ok0 := tokens[addresses[0]]
+		metadata1, ok1 := tokens[addresses[1]]
+		if !ok0 || !ok1 {
+			log.Warnf("UniswapV2 - missing token metadata for pair %s.", pairAddress.Hex())
+			continue
+		}
+		token0 := UniswapToken{
+			Address:  addresses[0],
+			Symbol:   metadata0.Symbol,
+			Decimals: metadata0.Decimals,
+			Name:     metadata0.Name,
+		}
+		token1 := UniswapToken{
+			Address:  addresses[1],
+			Symbol:   metadata1.Symbol,
+			Decimals: metadata1.Decimals,
+			Name:     metadata1.Name,
+		}
+		pairs[pairAddress] = UniswapPair{
+			ForeignName: token0.Symbol + "-" + token1.Symbol,
+			Address:     pairAddress,
+			Token0:      token0,
+			Token1:      token1,
+		}
+	}
+	return pairs, nil
 }
 
 // normalizeUniswapSwap takes a swap as returned by the swap contract's channel and converts it to a UniswapSwap type
@@ -323,8 +756,29 @@ func asset2UniAsset(asset models.Asset) UniswapToken {
 	}
 }
 
-// getSwapData returns price, volume and sell/buy information of @swap
-func getSwapData(swap UniswapSwap) (price float64, volume float64) {
+func uniToken2Asset(token UniswapToken) models.Asset {
+	return models.Asset{
+		Address:    token.Address.Hex(),
+		Symbol:     token.Symbol,
+		Name:       token.Name,
+		Decimals:   token.Decimals,
+		Blockchain: utils.ETHEREUM,
+	}
+}
+
+// getSwapData returns price, volume and sell/buy information of @swap.
+// For @order 0, price and volume refer to token0 in units of token1. For @order 1, it is the other way around.
+func getSwapData(swap UniswapSwap, order uint8) (price float64, volume float64) {
+	if order == 1 {
+		if swap.Amount1In == float64(0) {
+			volume = swap.Amount1Out
+			price = swap.Amount0In / swap.Amount1Out
+			return
+		}
+		volume = -swap.Amount1In
+		price = swap.Amount0Out / swap.Amount1In
+		return
+	}
 	if swap.Amount0In == float64(0) {
 		volume = swap.Amount0Out
 		price = swap.Amount1In / swap.Amount0Out
@@ -0,0 +1,103 @@
+package scrapers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetSwapData(t *testing.T) {
+	cases := []struct {
+		name   string
+		swap   UniswapSwap
+		order  uint8
+		price  float64
+		volume float64
+	}{
+		{
+			name:   "buy token0",
+			swap:   UniswapSwap{Amount1In: 3000, Amount0Out: 1},
+			order:  0,
+			price:  3000,
+			volume: 1,
+		},
+		{
+			name:   "sell token0",
+			swap:   UniswapSwap{Amount0In: 2, Amount1Out: 6000},
+			order:  0,
+			price:  3000,
+			volume: -2,
+		},
+		{
+			name:   "buy token1 reversed",
+			swap:   UniswapSwap{Amount0In: 2, Amount1Out: 6000},
+			order:  1,
+			price:  1.0 / 3000,
+			volume: 6000,
+		},
+		{
+			name:   "sell token1 reversed",
+			swap:   UniswapSwap{Amount1In: 3000, Amount0Out: 1},
+			order:  1,
+			price:  1.0 / 3000,
+			volume: -3000,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			price, volume := getSwapData(c.swap, c.order)
+			if math.Abs(price-c.price) > 1e-12 || volume != c.volume {
+				t.Errorf("getSwapData() = (%v, %v), want (%v, %v)", price, volume, c.price, c.volume)
+			}
+		})
+	}
+}
+
+func TestReserveInBefore(t *testing.T) {
+	address := common.HexToAddress("0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852")
+	scraper := UniswapV2Scraper{
+		reservesMap: map[string]models.Pool{
+			address.Hex(): {Assetvolumes: []models.AssetVolume{{Index: 0, Volume: 50}, {Index: 1, Volume: 150000}}},
+		},
+		syncs: map[string][]reserveSync{
+			address.Hex(): {
+				{blockNumber: 100, logIndex: 4, reserve0: 100, reserve1: 300000},
+				// Sync emitted by the swap at log 8, which sold 20 token0.
+				{blockNumber: 100, logIndex: 7, reserve0: 120, reserve1: 250000},
+				{blockNumber: 101, logIndex: 2, reserve0: 90, reserve1: 333000},
+			},
+		},
+	}
+
+	cases := []struct {
+		name      string
+		swap      dexSwap
+		reserveIn float64
+	}{
+		{
+			name:      "own sync",
+			swap:      dexSwap{trade: models.Trade{BlockNumber: 100}, logIndex: 8, zeroForOne: true, amountIn: 20},
+			reserveIn: 100,
+		},
+		{
+			name:      "previous sync",
+			swap:      dexSwap{trade: models.Trade{BlockNumber: 100}, logIndex: 6, zeroForOne: false, amountIn: 1000},
+			reserveIn: 300000,
+		},
+		{
+			name:      "no sync before swap",
+			swap:      dexSwap{trade: models.Trade{BlockNumber: 99}, logIndex: 1, zeroForOne: true, amountIn: 1},
+			reserveIn: 50,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if reserveIn := scraper.reserveInBefore(address, c.swap); reserveIn != c.reserveIn {
+				t.Errorf("reserveInBefore() = %v, want %v", reserveIn, c.reserveIn)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package scrapers
+
+import (
+	"context"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+)
+
+// newTradesChannel returns a channel for trades with capacity TRADES_CHANNEL_SIZE.
+func newTradesChannel() chan models.Trade {
+	return make(chan models.Trade, tradesChannelSize)
+}
+
+// sendTrade sends @trade to @tradesChannel. The time spent waiting for a full channel is
+// added to the blocked time of @exchange. The trade is dropped on cancellation of @ctx.
+func sendTrade(ctx context.Context, exchange string, tradesChannel chan models.Trade, trade models.Trade) {
+	select {
+	case tradesChannel <- trade:
+		return
+	default:
+	}
+	start := time.Now()
+	select {
+	case tradesChannel <- trade:
+	case <-ctx.Done():
+	}
+	scraperSendBlocked.WithLabelValues(exchange).Add(time.Since(start).Seconds())
+}
@@ -0,0 +1,183 @@
+package scrapers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	dexFilterReasonSandwich    = "sandwich"
+	dexFilterReasonPriceImpact = "price_impact"
+)
+
+// dexSwap is a DEX trade together with the on-chain information needed for trade-quality checks.
+type dexSwap struct {
+	trade     models.Trade
+	txIndex   uint
+	logIndex  uint
+	sender    common.Address
+	recipient common.Address
+	// zeroForOne is true if token0 is sold into the pool.
+	zeroForOne bool
+	// amountIn is the normalized amount of the token sold into the pool.
+	amountIn float64
+	// reserveIn is the normalized reserve of the token sold into the pool right before the swap.
+	reserveIn float64
+}
+
+// dexTradeFilter collects all swaps of a pool block by block and forwards only those trades
+// that pass the quality checks to @tradesChannel.
+type dexTradeFilter struct {
+	exchange       string
+	pool           string
+	maxPriceImpact float64
+	// reserveIn returns the reserveIn of a swap. It is nil if the swaps carry their reserves.
+	reserveIn     func(swap dexSwap) float64
+	flushDelay    time.Duration
+	swapsChannel  chan dexSwap
+	tradesChannel chan models.Trade
+}
+
+func newDEXTradeFilter(
+	exchange string,
+	pool string,
+	maxPriceImpact float64,
+	reserveIn func(swap dexSwap) float64,
+	flushDelay time.Duration,
+	tradesChannel chan models.Trade,
+) *dexTradeFilter {
+	return &dexTradeFilter{
+		exchange:       exchange,
+		pool:           pool,
+		maxPriceImpact: maxPriceImpact,
+		reserveIn:      reserveIn,
+		flushDelay:     flushDelay,
+		swapsChannel:   make(chan dexSwap),
+		tradesChannel:  tradesChannel,
+	}
+}
+
+// run buffers swaps until a swap from a later block arrives or no swap arrived for @flushDelay.
+// Then, the buffered block is checked and the remaining trades are forwarded.
+// It returns on cancellation of @ctx.
+func (f *dexTradeFilter) run(ctx context.Context) {
+	var (
+		block       []dexSwap
+		blockNumber uint64
+	)
+	flushTimer := time.NewTimer(f.flushDelay)
+	for {
+		select {
+		case swap := <-f.swapsChannel:
+			if len(block) > 0 && swap.trade.BlockNumber != blockNumber {
+				f.flush(ctx, block)
+				block = nil
+			}
+			blockNumber = swap.trade.BlockNumber
+			block = append(block, swap)
+			if !flushTimer.Stop() {
+				select {
+				case <-flushTimer.C:
+				default:
+				}
+			}
+			flushTimer.Reset(f.flushDelay)
+		case <-flushTimer.C:
+			if len(block) > 0 {
+				f.flush(ctx, block)
+				block = nil
+			}
+			flushTimer.Reset(f.flushDelay)
+		case <-ctx.Done():
+			flushTimer.Stop()
+			return
+		}
+	}
+}
+
+func (f *dexTradeFilter) flush(ctx context.Context, block []dexSwap) {
+	swaps, sandwiched := filterSandwiches(block)
+	if sandwiched > 0 {
+		log.Warnf("%s - removed %v sandwich swaps in block %v of pool %s.", f.exchange, sandwiched, block[0].trade.BlockNumber, f.pool)
+		dexFilteredTrades.WithLabelValues(f.exchange, dexFilterReasonSandwich).Add(float64(sandwiched))
+	}
+	if f.reserveIn != nil {
+		// Reserves are looked up only now, as the reserve updates of a block may arrive after its swaps.
+		for i := range swaps {
+			swaps[i].reserveIn = f.reserveIn(swaps[i])
+		}
+	}
+	swaps, highImpact := filterPriceImpact(swaps, f.maxPriceImpact)
+	if highImpact > 0 {
+		log.Warnf("%s - removed %v swaps with price impact above %v in block %v of pool %s.", f.exchange, highImpact, f.maxPriceImpact, block[0].trade.BlockNumber, f.pool)
+		dexFilteredTrades.WithLabelValues(f.exchange, dexFilterReasonPriceImpact).Add(float64(highImpact))
+	}
+	for _, swap := range swaps {
+		sendTrade(ctx, f.exchange, f.tradesChannel, swap.trade)
+	}
+}
+
+// filterSandwiches removes front-run/back-run pairs from @swaps, which are assumed to be swaps of one pool in one block.
+// A pair of swaps is considered a sandwich if both swaps pay out to the same recipient in opposite directions, and
+// there is a swap of another recipient in the same direction as the front-run in a transaction in between.
+func filterSandwiches(swaps []dexSwap) (cleanedSwaps []dexSwap, removedSwaps int) {
+	sorted := make([]dexSwap, len(swaps))
+	copy(sorted, swaps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].txIndex != sorted[j].txIndex {
+			return sorted[i].txIndex < sorted[j].txIndex
+		}
+		return sorted[i].logIndex < sorted[j].logIndex
+	})
+
+	flagged := make([]bool, len(sorted))
+	for i := range sorted {
+		if flagged[i] {
+			continue
+		}
+		for k := i + 1; k < len(sorted); k++ {
+			front, back := sorted[i], sorted[k]
+			if flagged[k] || front.recipient != back.recipient || front.zeroForOne == back.zeroForOne {
+				continue
+			}
+			for j := i + 1; j < k; j++ {
+				victim := sorted[j]
+				if victim.recipient != front.recipient &&
+					victim.zeroForOne == front.zeroForOne &&
+					front.txIndex < victim.txIndex && victim.txIndex < back.txIndex {
+					flagged[i], flagged[k] = true, true
+					break
+				}
+			}
+			if flagged[i] {
+				break
+			}
+		}
+	}
+
+	for i, swap := range sorted {
+		if flagged[i] {
+			removedSwaps++
+			continue
+		}
+		cleanedSwaps = append(cleanedSwaps, swap)
+	}
+	return
+}
+
+// filterPriceImpact removes all swaps from @swaps that sell more than the share @maxPriceImpact of the pool's reserves.
+// Swaps without known reserves are kept. @maxPriceImpact <= 0 disables the check.
+func filterPriceImpact(swaps []dexSwap, maxPriceImpact float64) (cleanedSwaps []dexSwap, removedSwaps int) {
+	for _, swap := range swaps {
+		if maxPriceImpact > 0 && swap.reserveIn > 0 && swap.amountIn/swap.reserveIn > maxPriceImpact {
+			removedSwaps++
+			continue
+		}
+		cleanedSwaps = append(cleanedSwaps, swap)
+	}
+	return
+}
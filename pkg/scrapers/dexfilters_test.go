@@ -0,0 +1,89 @@
+package scrapers
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	bot    = common.HexToAddress("0x0000000000000000000000000000000000000b07")
+	victim = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	trader = common.HexToAddress("0x0000000000000000000000000000000000000002")
+)
+
+func TestFilterSandwiches(t *testing.T) {
+	cases := []struct {
+		name         string
+		swaps        []dexSwap
+		removedSwaps int
+		remaining    []uint
+	}{
+		{
+			name: "sandwich around victim",
+			swaps: []dexSwap{
+				{txIndex: 3, recipient: bot, zeroForOne: false},
+				{txIndex: 1, recipient: bot, zeroForOne: true},
+				{txIndex: 2, recipient: victim, zeroForOne: true},
+			},
+			removedSwaps: 2,
+			remaining:    []uint{2},
+		},
+		{
+			name: "round trip without victim",
+			swaps: []dexSwap{
+				{txIndex: 1, recipient: bot, zeroForOne: true},
+				{txIndex: 2, recipient: victim, zeroForOne: false},
+				{txIndex: 3, recipient: bot, zeroForOne: false},
+			},
+			removedSwaps: 0,
+			remaining:    []uint{1, 2, 3},
+		},
+		{
+			name: "same direction is no sandwich",
+			swaps: []dexSwap{
+				{txIndex: 1, recipient: bot, zeroForOne: true},
+				{txIndex: 2, recipient: victim, zeroForOne: true},
+				{txIndex: 3, recipient: bot, zeroForOne: true},
+				{txIndex: 4, recipient: trader, zeroForOne: false},
+			},
+			removedSwaps: 0,
+			remaining:    []uint{1, 2, 3, 4},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cleanedSwaps, removedSwaps := filterSandwiches(c.swaps)
+			if removedSwaps != c.removedSwaps {
+				t.Errorf("removed swaps: got %v, want %v", removedSwaps, c.removedSwaps)
+			}
+			if len(cleanedSwaps) != len(c.remaining) {
+				t.Fatalf("remaining swaps: got %v, want %v", len(cleanedSwaps), len(c.remaining))
+			}
+			for i, swap := range cleanedSwaps {
+				if swap.txIndex != c.remaining[i] {
+					t.Errorf("remaining swap %d: got tx %v, want tx %v", i, swap.txIndex, c.remaining[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterPriceImpact(t *testing.T) {
+	swaps := []dexSwap{
+		{txIndex: 1, amountIn: 1, reserveIn: 100},
+		{txIndex: 2, amountIn: 20, reserveIn: 100},
+		{txIndex: 3, amountIn: 20, reserveIn: 0},
+	}
+
+	cleanedSwaps, removedSwaps := filterPriceImpact(swaps, 0.1)
+	if removedSwaps != 1 || len(cleanedSwaps) != 2 || cleanedSwaps[0].txIndex != 1 || cleanedSwaps[1].txIndex != 3 {
+		t.Errorf("filterPriceImpact() removed %v swaps, remaining %v", removedSwaps, cleanedSwaps)
+	}
+
+	_, removedSwaps = filterPriceImpact(swaps, 0)
+	if removedSwaps != 0 {
+		t.Errorf("filterPriceImpact() with disabled check removed %v swaps", removedSwaps)
+	}
+}
@@ -1,6 +1,8 @@
 package scrapers
 
 import (
+	"strconv"
+
 	models "github.com/diadata-org/decentral-feeder/pkg/models"
 	"github.com/diadata-org/decentral-feeder/pkg/utils"
 	"github.com/sirupsen/logrus"
@@ -14,13 +16,17 @@ const (
 	KRAKEN_EXCHANGE       = "Kraken"
 	KUCOIN_EXCHANGE       = "KuCoin"
 
-	UNISWAPV2_EXCHANGE = "UniswapV2"
-	Simulation         = "Simulation"
+	UNISWAPV2_EXCHANGE      = "UniswapV2"
+	UNISWAPV2_TWAP_EXCHANGE = "UniswapV2TWAP"
+	UNISWAPV3_TWAP_EXCHANGE = "UniswapV3TWAP"
+	Simulation              = "Simulation"
 )
 
 var (
 	Exchanges = make(map[string]models.Exchange)
 	log       *logrus.Logger
+	// tradesChannelSize is the capacity of the channels carrying trades from the scrapers to the Collector.
+	tradesChannelSize int
 )
 
 func init() {
@@ -34,6 +40,8 @@ func init() {
 
 	Exchanges[Simulation] = models.Exchange{Name: Simulation, Centralized: false, Blockchain: utils.ETHEREUM}
 	Exchanges[UNISWAPV2_EXCHANGE] = models.Exchange{Name: UNISWAPV2_EXCHANGE, Centralized: false, Blockchain: utils.ETHEREUM}
+	Exchanges[UNISWAPV2_TWAP_EXCHANGE] = models.Exchange{Name: UNISWAPV2_TWAP_EXCHANGE, Centralized: false, Blockchain: utils.ETHEREUM}
+	Exchanges[UNISWAPV3_TWAP_EXCHANGE] = models.Exchange{Name: UNISWAPV3_TWAP_EXCHANGE, Centralized: false, Blockchain: utils.ETHEREUM}
 
 	log = logrus.New()
 	loglevel, err := logrus.ParseLevel(utils.Getenv("LOG_LEVEL_SCRAPERS", "info"))
@@ -42,4 +50,10 @@ func init() {
 	}
 	log.SetLevel(loglevel)
 
+	tradesChannelSize, err = strconv.Atoi(utils.Getenv("TRADES_CHANNEL_SIZE", "1000"))
+	if err != nil || tradesChannelSize < 0 {
+		log.Errorf("Parse TRADES_CHANNEL_SIZE: %v.", err)
+		tradesChannelSize = 1000
+	}
+
 }
@@ -0,0 +1,68 @@
+package scrapers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	dexFilteredTrades = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "dex_filtered_trades_total",
+			Help:      "Number of DEX trades removed by the trade-quality stage before reaching the Collector.",
+		},
+		[]string{"exchange", "reason"},
+	)
+	simulationPriceImpact = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "simulation_price_impact_ratio",
+			Help:      "Relative difference between the effective price of a simulated swap of the given notional and the spot price.",
+		},
+		[]string{"symbol", "notional"},
+	)
+	scraperRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "scraper_restarts_total",
+			Help:      "Number of scraper restarts by the supervisor.",
+		},
+		[]string{"exchange"},
+	)
+	scraperState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "feeder",
+			Name:      "scraper_state",
+			Help:      "State of a supervised scraper: 0 running, 1 waiting for restart, 2 quarantined.",
+		},
+		[]string{"exchange"},
+	)
+	collectorDroppedTrades = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "collector_dropped_trades_total",
+			Help:      "Number of trades dropped by the Collector because the buffer of their market was full.",
+		},
+		[]string{"exchange"},
+	)
+	scraperSendBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "feeder",
+			Name:      "scraper_send_blocked_seconds_total",
+			Help:      "Time scrapers spent waiting to hand trades to the Collector.",
+		},
+		[]string{"exchange"},
+	)
+)
+
+// Metrics returns all prometheus collectors of the scrapers.
+func Metrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		dexFilteredTrades,
+		simulationPriceImpact,
+		scraperRestarts,
+		scraperState,
+		collectorDroppedTrades,
+		scraperSendBlocked,
+	}
+}
@@ -1,77 +1,259 @@
 package simulation
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"time"
 
-	coreEntities "github.com/daoleno/uniswap-sdk-core/entities"
+	"github.com/diadata-org/decentral-feeder/pkg/contracts/uniswapv3"
 	"github.com/diadata-org/decentral-feeder/pkg/models"
 	"github.com/sirupsen/logrus"
 
-	"github.com/daoleno/uniswapv3-sdk/examples/contract"
 	"github.com/daoleno/uniswapv3-sdk/examples/helper"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-const (
-	SlippageAmount = 3000
+// FeeTiers are the fee tiers of UniswapV3 pools in hundredths of a bip.
+var FeeTiers = []uint32{100, 500, 3000, 10000}
 
-	MainnetChainId = 1
-)
+// ChainDefaults holds the QuoterV2 and wrapped native token addresses of a chain.
+type ChainDefaults struct {
+	Quoter       common.Address
+	Intermediate common.Address
+}
+
+// DefaultAddresses maps chain IDs onto the canonical UniswapV3 deployment of the respective chain.
+var DefaultAddresses = map[uint64]ChainDefaults{
+	// Ethereum
+	1: {
+		Quoter:       common.HexToAddress("0x61fFE014bA17989E743c5F6cB21bF9697530B21e"),
+		Intermediate: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+	},
+	// Optimism
+	10: {
+		Quoter:       common.HexToAddress("0x61fFE014bA17989E743c5F6cB21bF9697530B21e"),
+		Intermediate: common.HexToAddress("0x4200000000000000000000000000000000000006"),
+	},
+	// Polygon
+	137: {
+		Quoter:       common.HexToAddress("0x61fFE014bA17989E743c5F6cB21bF9697530B21e"),
+		Intermediate: common.HexToAddress("0x0d500B1d8E8eF31E21C99d1Db9A6444d3ADf1270"),
+	},
+	// Base
+	8453: {
+		Quoter:       common.HexToAddress("0x3d4e44Eb1374240CE5F1B871ab261CD16335B76a"),
+		Intermediate: common.HexToAddress("0x4200000000000000000000000000000000000006"),
+	},
+	// Arbitrum
+	42161: {
+		Quoter:       common.HexToAddress("0x61fFE014bA17989E743c5F6cB21bF9697530B21e"),
+		Intermediate: common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"),
+	},
+}
 
 var (
 	SwapRouter = common.HexToAddress(helper.ContractV3SwapRouterV1)
+
+	errNoRoute = errors.New("no route found")
 )
 
 type Simulator struct {
-	Eth *ethclient.Client
-	log *logrus.Logger
+	Eth          *ethclient.Client
+	ChainID      uint64
+	quoter       *uniswapv3.QuoterV2CallerRaw
+	intermediate common.Address
+	log          *logrus.Logger
 }
 
-func New(client *ethclient.Client, log *logrus.Logger) *Simulator {
-	c := Simulator{Eth: client, log: log}
-	return &c
-
+// Quote is the best executable swap found by the simulator.
+type Quote struct {
+	// BlockNumber is the block the quote was computed at.
+	BlockNumber uint64
+	AmountIn    *big.Int
+	AmountOut   *big.Int
+	// Path is the sequence of tokens swapped through, starting with the input token.
+	Path []common.Address
+	// Fees contains the fee tier of the pool used for each hop in Path.
+	Fees []uint32
+	// SqrtPriceX96After contains the price of each pool after the swap.
+	SqrtPriceX96After []*big.Int
+	GasEstimate       *big.Int
 }
 
-func (c *Simulator) Execute(t1 models.Asset, t2 models.Asset) (string, error) {
+// chainIDTimeout bounds the request of the chain ID in New.
+const chainIDTimeout = 30 * time.Second
 
-	token1 := coreEntities.NewToken(1, common.HexToAddress(t1.Address), uint(t1.Decimals), t1.Name, t1.Name)
+// New returns a simulator on the chain @client is connected to. Zero addresses for @quoter
+// and @intermediate are replaced by the default deployment of the chain.
+// The chain ID is requested within @ctx and at most chainIDTimeout.
+func New(ctx context.Context, client *ethclient.Client, quoter common.Address, intermediate common.Address, log *logrus.Logger) (*Simulator, error) {
+	if client == nil {
+		return nil, errors.New("no client")
+	}
+	ctx, cancel := context.WithTimeout(ctx, chainIDTimeout)
+	defer cancel()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	token2 := coreEntities.NewToken(1, common.HexToAddress(t2.Address), uint(t2.Decimals), t2.Name, t2.Name)
+	defaults, ok := DefaultAddresses[chainID.Uint64()]
+	if quoter == (common.Address{}) {
+		if !ok {
+			return nil, fmt.Errorf("no default quoter for chain %d", chainID.Uint64())
+		}
+		quoter = defaults.Quoter
+	}
+	if intermediate == (common.Address{}) && ok {
+		intermediate = defaults.Intermediate
+	}
 
-	return c.quoteTokens("1000", token2, token1)
+	// The quoter functions are nonpayable, as they simulate the swap and revert with the result.
+	// They are only ever called, never sent, hence the raw caller.
+	quoterContract, err := uniswapv3.NewQuoterV2Caller(quoter, client)
+	if err != nil {
+		return nil, err
+	}
 
+	c := Simulator{
+		Eth:          client,
+		ChainID:      chainID.Uint64(),
+		quoter:       &uniswapv3.QuoterV2CallerRaw{Contract: quoterContract},
+		intermediate: intermediate,
+		log:          log,
+	}
+	return &c, nil
 }
 
-func (c *Simulator) quoteTokens(input string, token0 *coreEntities.Token, token1 *coreEntities.Token) (string, error) {
-	quoterContract, err := contract.NewUniswapv3Quoter(common.HexToAddress(helper.ContractV3Quoter), c.Eth)
+// Execute returns the best quote for swapping @amount units of @t2 into @t1 at block @blockNumber.
+// Quotes at the same block are deterministic and can be reproduced on an archive node.
+func (c *Simulator) Execute(t1 models.Asset, t2 models.Asset, amount string, blockNumber uint64) (Quote, error) {
+	amountIn := helper.FloatStringToBigInt(amount, int(t2.Decimals))
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return Quote{}, fmt.Errorf("invalid amount %s", amount)
+	}
+	opts := &bind.CallOpts{BlockNumber: new(big.Int).SetUint64(blockNumber)}
+	quote, err := c.quoteTokens(opts, amountIn, common.HexToAddress(t2.Address), common.HexToAddress(t1.Address))
 	if err != nil {
-		c.log.Errorln("failed to create quoter contract")
-		return "", err
+		return Quote{}, err
 	}
-	// 0.03% slippage
-	fee := big.NewInt(SlippageAmount)
+	quote.BlockNumber = blockNumber
+	return quote, nil
+}
 
-	amountIn := helper.FloatStringToBigInt(input, int(token0.Decimals()))
-	sqrtPriceLimitX96 := big.NewInt(0)
+// quoteTokens compares the best direct swap with the best swap routed through the intermediate token.
+func (c *Simulator) quoteTokens(opts *bind.CallOpts, amountIn *big.Int, tokenIn common.Address, tokenOut common.Address) (Quote, error) {
+	best, err := c.quoteSingle(opts, amountIn, tokenIn, tokenOut)
+	if err != nil {
+		c.log.Debugf("Simulator - no direct pool for %s -> %s: %v", tokenIn.Hex(), tokenOut.Hex(), err)
+	}
 
-	var out []interface{}
+	if c.intermediate != (common.Address{}) && tokenIn != c.intermediate && tokenOut != c.intermediate {
+		routed, errRoute := c.quoteRouted(opts, amountIn, tokenIn, tokenOut)
+		if errRoute != nil {
+			c.log.Debugf("Simulator - no route via %s for %s -> %s: %v", c.intermediate.Hex(), tokenIn.Hex(), tokenOut.Hex(), errRoute)
+		} else if best.AmountOut == nil || routed.AmountOut.Cmp(best.AmountOut) > 0 {
+			best = routed
+		}
+	}
 
-	rawCaller := &contract.Uniswapv3QuoterRaw{Contract: quoterContract}
+	if best.AmountOut == nil {
+		return Quote{}, errNoRoute
+	}
+	c.log.Debugf("Quote: input: %s, output: %s, path: %v, fees: %v", amountIn.String(), best.AmountOut.String(), best.Path, best.Fees)
+	return best, nil
+}
 
-	err = rawCaller.Call(&bind.CallOpts{}, &out, "quoteExactInputSingle", token0.Address, token1.Address,
-		fee, amountIn, sqrtPriceLimitX96)
-	if err != nil {
-		c.log.Errorln("failed to call quoteExactInputSingle")
-		return "", err
+// quoteSingle quotes all fee tiers of the pools of the pair and returns the one with maximal output.
+func (c *Simulator) quoteSingle(opts *bind.CallOpts, amountIn *big.Int, tokenIn common.Address, tokenOut common.Address) (Quote, error) {
+	var best Quote
+	for _, fee := range FeeTiers {
+		result, err := c.quoteExactInputSingle(opts, uniswapv3.IQuoterV2QuoteExactInputSingleParams{
+			TokenIn:           tokenIn,
+			TokenOut:          tokenOut,
+			AmountIn:          amountIn,
+			Fee:               big.NewInt(int64(fee)),
+			SqrtPriceLimitX96: big.NewInt(0),
+		})
+		if err != nil {
+			// The quoter reverts if the pool does not exist or lacks liquidity.
+			continue
+		}
+		if result.AmountOut == nil || result.AmountOut.Sign() <= 0 {
+			continue
+		}
+		if best.AmountOut == nil || result.AmountOut.Cmp(best.AmountOut) > 0 {
+			best = Quote{
+				AmountIn:          amountIn,
+				AmountOut:         result.AmountOut,
+				Path:              []common.Address{tokenIn, tokenOut},
+				Fees:              []uint32{fee},
+				SqrtPriceX96After: []*big.Int{result.SqrtPriceX96After},
+				GasEstimate:       result.GasEstimate,
+			}
+		}
 	}
+	if best.AmountOut == nil {
+		return Quote{}, errNoRoute
+	}
+	return best, nil
+}
+
+// exactInputSingleResult is the output of QuoterV2's quoteExactInputSingle.
+type exactInputSingleResult struct {
+	AmountOut               *big.Int
+	SqrtPriceX96After       *big.Int
+	InitializedTicksCrossed uint32
+	GasEstimate             *big.Int
+}
 
-	c.log.Debugf("Quote: input: %s, output: %s", input, out[0].(*big.Int).String())
+// quoteExactInputSingle calls quoteExactInputSingle of the quoter with @params.
+func (c *Simulator) quoteExactInputSingle(opts *bind.CallOpts, params uniswapv3.IQuoterV2QuoteExactInputSingleParams) (exactInputSingleResult, error) {
+	var out []interface{}
+	if err := c.quoter.Call(opts, &out, "quoteExactInputSingle", params); err != nil {
+		return exactInputSingleResult{}, err
+	}
+	if len(out) < 4 {
+		return exactInputSingleResult{}, errors.New("unexpected output of quoteExactInputSingle")
+	}
+	var (
+		result             exactInputSingleResult
+		ok0, ok1, ok2, ok3 bool
+	)
+	result.AmountOut, ok0 = out[0].(*big.Int)
+	result.SqrtPriceX96After, ok1 = out[1].(*big.Int)
+	result.InitializedTicksCrossed, ok2 = out[2].(uint32)
+	result.GasEstimate, ok3 = out[3].(*big.Int)
+	if !ok0 || !ok1 || !ok2 || !ok3 {
+		return exactInputSingleResult{}, errors.New("unexpected output of quoteExactInputSingle")
+	}
+	return result, nil
+}
 
-	return CurrencyToString(out[0].(*big.Int), int(token1.Decimals())), nil
+// quoteRouted swaps into the intermediate token and from there into @tokenOut,
+// using the best fee tier for each hop.
+func (c *Simulator) quoteRouted(opts *bind.CallOpts, amountIn *big.Int, tokenIn common.Address, tokenOut common.Address) (Quote, error) {
+	first, err := c.quoteSingle(opts, amountIn, tokenIn, c.intermediate)
+	if err != nil {
+		return Quote{}, err
+	}
+	second, err := c.quoteSingle(opts, first.AmountOut, c.intermediate, tokenOut)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{
+		AmountIn:          amountIn,
+		AmountOut:         second.AmountOut,
+		Path:              []common.Address{tokenIn, c.intermediate, tokenOut},
+		Fees:              append(first.Fees, second.Fees...),
+		SqrtPriceX96After: append(first.SqrtPriceX96After, second.SqrtPriceX96After...),
+		GasEstimate:       new(big.Int).Add(first.GasEstimate, second.GasEstimate),
+	}, nil
 }
 
 func CurrencyToString(units *big.Int, decimals int) string {
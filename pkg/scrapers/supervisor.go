@@ -0,0 +1,248 @@
+package scrapers
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+)
+
+// ScraperState is the state of a supervised scraper.
+type ScraperState int
+
+const (
+	ScraperRunning ScraperState = iota
+	ScraperBackoff
+	ScraperQuarantined
+)
+
+func (state ScraperState) String() string {
+	switch state {
+	case ScraperRunning:
+		return "running"
+	case ScraperBackoff:
+		return "backoff"
+	case ScraperQuarantined:
+		return "quarantined"
+	}
+	return "unknown"
+}
+
+// RestartPolicy determines when a failed scraper is restarted.
+type RestartPolicy struct {
+	// The delay before the n-th consecutive restart is InitialBackoff*2^(n-1), capped at MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the maximal relative deviation of a delay, i.e. 0.2 for ±20%.
+	Jitter float64
+	// An exchange with more than MaxRestarts restarts within Window is quarantined for Quarantine.
+	// A scraper that ran for longer than Window before failing starts over with InitialBackoff.
+	MaxRestarts int
+	Window      time.Duration
+	Quarantine  time.Duration
+}
+
+// RestartPolicyFromEnv returns the restart policy given by the SCRAPER_RESTART_* environment variables.
+func RestartPolicyFromEnv() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: time.Duration(utils.GetenvFloat("SCRAPER_RESTART_INITIAL_BACKOFF_SECONDS", 5)*1000) * time.Millisecond,
+		MaxBackoff:     time.Duration(utils.GetenvFloat("SCRAPER_RESTART_MAX_BACKOFF_SECONDS", 300)*1000) * time.Millisecond,
+		Jitter:         utils.GetenvFloat("SCRAPER_RESTART_JITTER", 0.2),
+		MaxRestarts:    int(utils.GetenvFloat("SCRAPER_RESTART_MAX_RESTARTS", 5)),
+		Window:         time.Duration(utils.GetenvFloat("SCRAPER_RESTART_WINDOW_SECONDS", 600)*1000) * time.Millisecond,
+		Quarantine:     time.Duration(utils.GetenvFloat("SCRAPER_QUARANTINE_SECONDS", 1800)*1000) * time.Millisecond,
+	}
+}
+
+// backoff returns the delay before the restart following @failures consecutive failures.
+func (policy RestartPolicy) backoff(failures int, random float64) time.Duration {
+	delay := float64(policy.InitialBackoff) * math.Pow(2, float64(failures-1))
+	if delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+	// @random in [0,1) is mapped onto a factor in [1-Jitter, 1+Jitter).
+	delay *= 1 + policy.Jitter*(2*random-1)
+	return time.Duration(delay)
+}
+
+// startFunc runs a scraper instance. It is RunScraper except in tests.
+type startFunc func(ctx context.Context, exchange string, pairs []models.ExchangePair, pools []models.Pool, failoverChannel chan string)
+
+// Supervisor runs at most one instance of each exchange's scraper and restarts failed instances
+// according to its RestartPolicy.
+type Supervisor struct {
+	ctx       context.Context
+	mu        sync.Mutex
+	policy    RestartPolicy
+	start     startFunc
+	scrapers  map[string]*supervisedScraper
+	nextID    uint64
+	stopped   bool
+	randFloat func() float64
+	// wg counts the running instances. It may be nil.
+	wg *sync.WaitGroup
+}
+
+type supervisedScraper struct {
+	exchange string
+	pairs    []models.ExchangePair
+	pools    []models.Pool
+	state    ScraperState
+	// id identifies the live instance. Failures reported by former instances are ignored.
+	id        uint64
+	cancel    context.CancelFunc
+	startTime time.Time
+	failures  int
+	restarts  []time.Time
+	timer     *time.Timer
+}
+
+// NewSupervisor returns a supervisor whose scrapers send their trades to @tradesChannel.
+// All scrapers are stopped on cancellation of @ctx. Each running instance is counted in @wg, so that waiting on
+// @wg after cancellation waits for all scrapers to return.
+func NewSupervisor(ctx context.Context, policy RestartPolicy, tradesChannel chan models.Trade, wg *sync.WaitGroup) *Supervisor {
+	s := newSupervisor(ctx, policy, func(ctx context.Context, exchange string, pairs []models.ExchangePair, pools []models.Pool, failoverChannel chan string) {
+		RunScraper(ctx, exchange, pairs, pools, tradesChannel, failoverChannel)
+	})
+	s.wg = wg
+	return s
+}
+
+func newSupervisor(ctx context.Context, policy RestartPolicy, start startFunc) *Supervisor {
+	return &Supervisor{
+		ctx:       ctx,
+		policy:    policy,
+		start:     start,
+		scrapers:  make(map[string]*supervisedScraper),
+		randFloat: rand.Float64,
+	}
+}
+
+// Start runs the scraper for @exchange with @pairs resp. @pools. It does nothing if the exchange is already supervised.
+func (s *Supervisor) Start(exchange string, pairs []models.ExchangePair, pools []models.Pool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scrapers[exchange]; ok {
+		log.Warnf("Supervisor - %s is already supervised.", exchange)
+		return
+	}
+	scraper := &supervisedScraper{exchange: exchange, pairs: pairs, pools: pools}
+	s.scrapers[exchange] = scraper
+	s.run(scraper)
+}
+
+// Stop stops all scrapers and pending restarts.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for _, scraper := range s.scrapers {
+		if scraper.timer != nil {
+			scraper.timer.Stop()
+		}
+		if scraper.cancel != nil {
+			scraper.cancel()
+		}
+	}
+}
+
+// State returns the state of the scraper for @exchange.
+func (s *Supervisor) State(exchange string) (ScraperState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scraper, ok := s.scrapers[exchange]
+	if !ok {
+		return 0, false
+	}
+	return scraper.state, true
+}
+
+// run starts a new instance of @scraper. The caller must hold the lock.
+func (s *Supervisor) run(scraper *supervisedScraper) {
+	s.nextID++
+	id := s.nextID
+	ctx, cancel := context.WithCancel(s.ctx)
+	scraper.id = id
+	scraper.cancel = cancel
+	scraper.state = ScraperRunning
+	scraper.startTime = time.Now()
+	scraperState.WithLabelValues(scraper.exchange).Set(float64(ScraperRunning))
+
+	// Each instance gets its own failover channel, so that a failure is attributed to the instance reporting it.
+	failoverChannel := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		// Each instance reports at most one failure.
+		select {
+		case <-failoverChannel:
+			s.handleFailure(scraper.exchange, id)
+		case <-done:
+		}
+	}()
+	if s.wg != nil {
+		s.wg.Add(1)
+	}
+	go func() {
+		s.start(ctx, scraper.exchange, scraper.pairs, scraper.pools, failoverChannel)
+		close(done)
+		if s.wg != nil {
+			s.wg.Done()
+		}
+	}()
+}
+
+// handleFailure schedules the restart of the failed instance @id of @exchange.
+func (s *Supervisor) handleFailure(exchange string, id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scraper, ok := s.scrapers[exchange]
+	if !ok || s.stopped || scraper.id != id || scraper.state != ScraperRunning {
+		return
+	}
+	scraper.cancel()
+
+	now := time.Now()
+	if now.Sub(scraper.startTime) > s.policy.Window {
+		scraper.failures = 0
+	}
+	scraper.failures++
+
+	var recent []time.Time
+	for _, t := range scraper.restarts {
+		if now.Sub(t) <= s.policy.Window {
+			recent = append(recent, t)
+		}
+	}
+	scraper.restarts = recent
+
+	var delay time.Duration
+	if len(scraper.restarts) >= s.policy.MaxRestarts {
+		scraper.state = ScraperQuarantined
+		scraper.failures = 0
+		scraper.restarts = nil
+		delay = s.policy.Quarantine
+		log.Errorf("Supervisor - %s failed %v times within %v. Quarantine for %v.", exchange, s.policy.MaxRestarts+1, s.policy.Window, delay)
+	} else {
+		scraper.state = ScraperBackoff
+		delay = s.policy.backoff(scraper.failures, s.randFloat())
+		log.Warnf("Supervisor - %s failed. Restart in %v.", exchange, delay)
+	}
+	scraperState.WithLabelValues(exchange).Set(float64(scraper.state))
+
+	scraper.timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// No instance is started after cancellation, as the shutdown may already wait for the running ones.
+		if s.stopped || s.ctx.Err() != nil || scraper.id != id {
+			return
+		}
+		scraper.restarts = append(scraper.restarts, time.Now())
+		scraperRestarts.WithLabelValues(exchange).Inc()
+		log.Infof("Supervisor - restart %s.", exchange)
+		s.run(scraper)
+	})
+}
@@ -0,0 +1,146 @@
+package scrapers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+)
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	policy := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Jitter: 0.5}
+	cases := []struct {
+		failures int
+		random   float64
+		delay    time.Duration
+	}{
+		{1, 0.5, time.Second},
+		{2, 0.5, 2 * time.Second},
+		{4, 0.5, 8 * time.Second},
+		{5, 0.5, 10 * time.Second},
+		{1, 0, 500 * time.Millisecond},
+		{2, 0.75, 2500 * time.Millisecond},
+	}
+	for i, c := range cases {
+		delay := policy.backoff(c.failures, c.random)
+		if delay != c.delay {
+			t.Errorf("backoff was incorrect, got: %v, expected: %v for set: %d", delay, c.delay, i)
+		}
+	}
+}
+
+// fakeScraper records the failover channel of each started instance and runs until cancelled.
+type fakeScraper struct {
+	instances chan chan string
+}
+
+func (f *fakeScraper) start(ctx context.Context, exchange string, pairs []models.ExchangePair, pools []models.Pool, failoverChannel chan string) {
+	f.instances <- failoverChannel
+	<-ctx.Done()
+}
+
+func (f *fakeScraper) next(t *testing.T, timeout time.Duration) chan string {
+	select {
+	case failoverChannel := <-f.instances:
+		return failoverChannel
+	case <-time.After(timeout):
+		t.Fatalf("no scraper instance started within %v.", timeout)
+	}
+	return nil
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	f := &fakeScraper{instances: make(chan chan string, 10)}
+	policy := RestartPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond, MaxRestarts: 2, Window: time.Minute, Quarantine: time.Hour}
+	s := newSupervisor(context.Background(), policy, f.start)
+	defer s.Stop()
+
+	s.Start(BINANCE_EXCHANGE, nil, nil)
+	first := f.next(t, time.Second)
+
+	// A second Start must not result in a second live instance.
+	s.Start(BINANCE_EXCHANGE, nil, nil)
+	select {
+	case <-f.instances:
+		t.Fatalf("duplicate instance started.")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first <- BINANCE_EXCHANGE
+	second := f.next(t, time.Second)
+	if state, _ := s.State(BINANCE_EXCHANGE); state != ScraperRunning {
+		t.Errorf("state after restart is %v, expected running.", state)
+	}
+
+	second <- BINANCE_EXCHANGE
+	third := f.next(t, time.Second)
+
+	// The third failure within the window exceeds MaxRestarts.
+	third <- BINANCE_EXCHANGE
+	time.Sleep(50 * time.Millisecond)
+	if state, _ := s.State(BINANCE_EXCHANGE); state != ScraperQuarantined {
+		t.Errorf("state after repeated failures is %v, expected quarantined.", state)
+	}
+	select {
+	case <-f.instances:
+		t.Fatalf("quarantined scraper was restarted.")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSupervisorRestartsDEXScraper(t *testing.T) {
+	// The ws client cannot connect, so each instance of the UniswapV2 scraper fails on startup.
+	t.Setenv(UNISWAPV2_EXCHANGE+"_URI_REST", "http://127.0.0.1:1")
+	t.Setenv(UNISWAPV2_EXCHANGE+"_URI_WS", "ws://127.0.0.1:1")
+	pools := []models.Pool{{Exchange: models.Exchange{Name: UNISWAPV2_EXCHANGE}, Address: "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"}}
+	starts := make(chan []models.Pool, 10)
+	policy := RestartPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond, MaxRestarts: 5, Window: time.Minute, Quarantine: time.Hour}
+	s := newSupervisor(context.Background(), policy, func(ctx context.Context, exchange string, pairs []models.ExchangePair, pools []models.Pool, failoverChannel chan string) {
+		starts <- pools
+		RunScraper(ctx, exchange, pairs, pools, newTradesChannel(), failoverChannel)
+	})
+	defer s.Stop()
+
+	s.Start(UNISWAPV2_EXCHANGE, nil, pools)
+	for i := 0; i < 3; i++ {
+		select {
+		case got := <-starts:
+			if len(got) != 1 || got[0].Address != pools[0].Address {
+				t.Fatalf("instance %v started with pools %v, expected %v.", i, got, pools)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("instance %v of the DEX scraper was not started.", i)
+		}
+	}
+}
+
+func TestSupervisorWaitGroup(t *testing.T) {
+	f := &fakeScraper{instances: make(chan chan string, 10)}
+	policy := RestartPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond, MaxRestarts: 2, Window: time.Minute, Quarantine: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	s := newSupervisor(ctx, policy, f.start)
+	s.wg = &wg
+
+	s.Start(BINANCE_EXCHANGE, nil, nil)
+	// The instance is counted as soon as it is started.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("wait returned while the scraper is running.")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return after cancellation.")
+	}
+}
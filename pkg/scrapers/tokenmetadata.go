@@ -0,0 +1,387 @@
+package scrapers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/decentral-feeder/pkg/contracts/multicall3"
+	"github.com/diadata-org/decentral-feeder/pkg/contracts/uniswap"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// Multicall3 is deployed at the same address on nearly all EVM chains.
+	multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+	// multicallBatchSize is the maximal number of calls aggregated into one eth_call.
+	multicallBatchSize = 300
+	// multicallTimeout bounds each aggregated eth_call.
+	multicallTimeout = 30 * time.Second
+)
+
+var (
+	sharedTokenMetadataCache     *tokenMetadataCache
+	sharedTokenMetadataCacheOnce sync.Once
+)
+
+// TokenMetadata is the immutable metadata of an ERC20 token.
+type TokenMetadata struct {
+	Symbol   string
+	Name     string
+	Decimals uint8
+}
+
+// TokenMetadataService fetches ERC20 metadata and pair tokens in batches through Multicall3.
+// Results are kept in a cache shared by all scrapers and persisted to TOKEN_METADATA_CACHE_FILE.
+// It is safe for concurrent use.
+type TokenMetadataService struct {
+	blockchain string
+	multicall  *multicall3.Multicall3Caller
+	erc20ABI   abi.ABI
+	pairABI    abi.ABI
+	cache      *tokenMetadataCache
+}
+
+// NewTokenMetadataService returns a metadata service for @blockchain using the node behind @caller.
+func NewTokenMetadataService(caller bind.ContractCaller, blockchain string) (*TokenMetadataService, error) {
+	if caller == nil {
+		return nil, errors.New("no client")
+	}
+	multicall, err := multicall3.NewMulticall3Caller(common.HexToAddress(utils.Getenv("MULTICALL3_ADDRESS", multicall3Address)), caller)
+	if err != nil {
+		return nil, err
+	}
+	erc20ABI, err := abi.JSON(strings.NewReader(uniswap.IERC20ABI))
+	if err != nil {
+		return nil, err
+	}
+	pairABI, err := abi.JSON(strings.NewReader(uniswap.IUniswapV2PairABI))
+	if err != nil {
+		return nil, err
+	}
+
+	sharedTokenMetadataCacheOnce.Do(func() {
+		sharedTokenMetadataCache = newTokenMetadataCache(utils.Getenv("TOKEN_METADATA_CACHE_FILE", "token_metadata.json"))
+	})
+
+	return &TokenMetadataService{
+		blockchain: blockchain,
+		multicall:  multicall,
+		erc20ABI:   erc20ABI,
+		pairABI:    pairABI,
+		cache:      sharedTokenMetadataCache,
+	}, nil
+}
+
+// GetToken returns the metadata of the token with address @address.
+func (s *TokenMetadataService) GetToken(ctx context.Context, address common.Address) (TokenMetadata, error) {
+	tokens, err := s.GetTokens(ctx, []common.Address{address})
+	if err != nil {
+		return TokenMetadata{}, err
+	}
+	token, ok := tokens[address]
+	if !ok {
+		return TokenMetadata{}, errors.New("no ERC20 metadata for " + address.Hex())
+	}
+	return token, nil
+}
+
+// GetTokens returns the metadata of all tokens in @addresses. Tokens not yet cached are fetched in
+// batches. Tokens whose decimals cannot be fetched are missing in the returned map.
+func (s *TokenMetadataService) GetTokens(ctx context.Context, addresses []common.Address) (map[common.Address]TokenMetadata, error) {
+	tokens := make(map[common.Address]TokenMetadata)
+	var missing []common.Address
+	seen := make(map[common.Address]bool)
+	for _, address := range addresses {
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		if token, ok := s.cache.getToken(s.blockchain, address); ok {
+			tokens[address] = token
+			continue
+		}
+		missing = append(missing, address)
+	}
+	if len(missing) == 0 {
+		return tokens, nil
+	}
+
+	var calls []multicall3.Multicall3Call3
+	for _, address := range missing {
+		for _, method := range []string{"decimals", "symbol", "name"} {
+			callData, err := s.erc20ABI.Pack(method)
+			if err != nil {
+				return tokens, err
+			}
+			calls = append(calls, multicall3.Multicall3Call3{Target: address, AllowFailure: true, CallData: callData})
+		}
+	}
+	results, err := s.aggregate(ctx, calls)
+	if err != nil {
+		return tokens, err
+	}
+
+	fetched := make(map[common.Address]TokenMetadata)
+	for i, address := range missing {
+		decimalsResult, symbolResult, nameResult := results[3*i], results[3*i+1], results[3*i+2]
+		if !decimalsResult.Success {
+			log.Warnf("TokenMetadata - decimals call failed for %s.", address.Hex())
+			continue
+		}
+		out, err := s.erc20ABI.Unpack("decimals", decimalsResult.ReturnData)
+		if err != nil || len(out) == 0 {
+			log.Warnf("TokenMetadata - unpack decimals of %s: %v.", address.Hex(), err)
+			continue
+		}
+		decimals, ok := out[0].(uint8)
+		if !ok {
+			continue
+		}
+		token := TokenMetadata{
+			Decimals: decimals,
+			Symbol:   s.unpackString("symbol", symbolResult),
+			Name:     s.unpackString("name", nameResult),
+		}
+		tokens[address] = token
+		fetched[address] = token
+	}
+	s.cache.setTokens(s.blockchain, fetched)
+	return tokens, nil
+}
+
+// GetPairTokens returns token0 and token1 of all UniswapV2 compatible pairs in @pairAddresses.
+func (s *TokenMetadataService) GetPairTokens(ctx context.Context, pairAddresses []common.Address) (map[common.Address][2]common.Address, error) {
+	pairs := make(map[common.Address][2]common.Address)
+	var missing []common.Address
+	seen := make(map[common.Address]bool)
+	for _, address := range pairAddresses {
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		if tokens, ok := s.cache.getPair(s.blockchain, address); ok {
+			pairs[address] = tokens
+			continue
+		}
+		missing = append(missing, address)
+	}
+	if len(missing) == 0 {
+		return pairs, nil
+	}
+
+	var calls []multicall3.Multicall3Call3
+	for _, address := range missing {
+		for _, method := range []string{"token0", "token1"} {
+			callData, err := s.pairABI.Pack(method)
+			if err != nil {
+				return pairs, err
+			}
+			calls = append(calls, multicall3.Multicall3Call3{Target: address, AllowFailure: true, CallData: callData})
+		}
+	}
+	results, err := s.aggregate(ctx, calls)
+	if err != nil {
+		return pairs, err
+	}
+
+	fetched := make(map[common.Address][2]common.Address)
+	for i, address := range missing {
+		token0, err0 := s.unpackAddress("token0", results[2*i])
+		token1, err1 := s.unpackAddress("token1", results[2*i+1])
+		if err0 != nil || err1 != nil {
+			log.Warnf("TokenMetadata - tokens of pair %s: %v, %v.", address.Hex(), err0, err1)
+			continue
+		}
+		pairs[address] = [2]common.Address{token0, token1}
+		fetched[address] = pairs[address]
+	}
+	s.cache.setPairs(s.blockchain, fetched)
+	return pairs, nil
+}
+
+// aggregate executes @calls in batches of at most multicallBatchSize calls. Each batch is bounded by multicallTimeout.
+func (s *TokenMetadataService) aggregate(ctx context.Context, calls []multicall3.Multicall3Call3) (results []multicall3.Multicall3Result, err error) {
+	for start := 0; start < len(calls); start += multicallBatchSize {
+		end := start + multicallBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		var batch []multicall3.Multicall3Result
+		callCtx, cancel := context.WithTimeout(ctx, multicallTimeout)
+		batch, err = s.multicall.Aggregate3(&bind.CallOpts{Context: callCtx}, calls[start:end])
+		cancel()
+		if err != nil {
+			return
+		}
+		if len(batch) != end-start {
+			err = errors.New("multicall returned unexpected number of results")
+			return
+		}
+		results = append(results, batch...)
+	}
+	return
+}
+
+// unpackString decodes a string return value. Some older tokens return bytes32 instead of string.
+func (s *TokenMetadataService) unpackString(method string, result multicall3.Multicall3Result) string {
+	if !result.Success {
+		return ""
+	}
+	out, err := s.erc20ABI.Unpack(method, result.ReturnData)
+	if err == nil && len(out) > 0 {
+		if str, ok := out[0].(string); ok {
+			return str
+		}
+	}
+	return decodeBytes32String(result.ReturnData)
+}
+
+func (s *TokenMetadataService) unpackAddress(method string, result multicall3.Multicall3Result) (common.Address, error) {
+	if !result.Success {
+		return common.Address{}, errors.New(method + " call failed")
+	}
+	out, err := s.pairABI.Unpack(method, result.ReturnData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	address, ok := out[0].(common.Address)
+	if !ok {
+		return common.Address{}, errors.New("unexpected type of " + method)
+	}
+	return address, nil
+}
+
+// decodeBytes32String returns the string encoded in a bytes32 return value, or an empty string.
+func decodeBytes32String(data []byte) string {
+	if len(data) != 32 {
+		return ""
+	}
+	return string(bytes.TrimRight(data, "\x00"))
+}
+
+// tokenMetadataCache holds token and pair metadata keyed by blockchain and address.
+type tokenMetadataCache struct {
+	mu   sync.RWMutex
+	path string
+	data tokenMetadataCacheData
+}
+
+type tokenMetadataCacheData struct {
+	Tokens map[string]TokenMetadata
+	Pairs  map[string][2]common.Address
+}
+
+// newTokenMetadataCache returns a cache persisted to @path. An empty path disables persistence.
+func newTokenMetadataCache(path string) *tokenMetadataCache {
+	c := &tokenMetadataCache{
+		path: path,
+		data: tokenMetadataCacheData{
+			Tokens: make(map[string]TokenMetadata),
+			Pairs:  make(map[string][2]common.Address),
+		},
+	}
+	if path == "" {
+		return c
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("TokenMetadata - read cache file %s: %v.", path, err)
+		}
+		return c
+	}
+	var data tokenMetadataCacheData
+	if err := json.Unmarshal(content, &data); err != nil {
+		log.Warnf("TokenMetadata - parse cache file %s: %v.", path, err)
+		return c
+	}
+	for key, token := range data.Tokens {
+		c.data.Tokens[key] = token
+	}
+	for key, pair := range data.Pairs {
+		c.data.Pairs[key] = pair
+	}
+	log.Infof("TokenMetadata - loaded %v tokens and %v pairs from %s.", len(c.data.Tokens), len(c.data.Pairs), path)
+	return c
+}
+
+func tokenMetadataKey(blockchain string, address common.Address) string {
+	return blockchain + ":" + address.Hex()
+}
+
+func (c *tokenMetadataCache) getToken(blockchain string, address common.Address) (TokenMetadata, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	token, ok := c.data.Tokens[tokenMetadataKey(blockchain, address)]
+	return token, ok
+}
+
+func (c *tokenMetadataCache) getPair(blockchain string, address common.Address) ([2]common.Address, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pair, ok := c.data.Pairs[tokenMetadataKey(blockchain, address)]
+	return pair, ok
+}
+
+func (c *tokenMetadataCache) setTokens(blockchain string, tokens map[common.Address]TokenMetadata) {
+	if len(tokens) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, token := range tokens {
+		c.data.Tokens[tokenMetadataKey(blockchain, address)] = token
+	}
+	c.save()
+}
+
+func (c *tokenMetadataCache) setPairs(blockchain string, pairs map[common.Address][2]common.Address) {
+	if len(pairs) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, pair := range pairs {
+		c.data.Pairs[tokenMetadataKey(blockchain, address)] = pair
+	}
+	c.save()
+}
+
+// save writes the cache to its file. The caller must hold the write lock.
+func (c *tokenMetadataCache) save() {
+	if c.path == "" {
+		return
+	}
+	content, err := json.MarshalIndent(c.data, "", "  ")
+	if err != nil {
+		log.Errorf("TokenMetadata - marshal cache: %v.", err)
+		return
+	}
+	// Write to a temporary file first so that a crash cannot leave a truncated cache behind.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		log.Errorf("TokenMetadata - write cache file: %v.", err)
+		return
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Errorf("TokenMetadata - write cache file: %v.", err)
+	}
+}
@@ -0,0 +1,44 @@
+package scrapers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTokenMetadataCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token_metadata.json")
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	pair := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	c := newTokenMetadataCache(path)
+	c.setTokens("Ethereum", map[common.Address]TokenMetadata{weth: {Symbol: "WETH", Name: "Wrapped Ether", Decimals: 18}})
+	c.setPairs("Ethereum", map[common.Address][2]common.Address{pair: {usdc, weth}})
+
+	// A new cache on the same file must contain the persisted entries.
+	c = newTokenMetadataCache(path)
+	token, ok := c.getToken("Ethereum", weth)
+	if !ok || token.Symbol != "WETH" || token.Decimals != 18 {
+		t.Errorf("getToken returned %v, %v.", token, ok)
+	}
+	if _, ok := c.getToken("Polygon", weth); ok {
+		t.Errorf("getToken must distinguish blockchains.")
+	}
+	tokens, ok := c.getPair("Ethereum", pair)
+	if !ok || tokens != [2]common.Address{usdc, weth} {
+		t.Errorf("getPair returned %v, %v.", tokens, ok)
+	}
+}
+
+func TestDecodeBytes32String(t *testing.T) {
+	data := make([]byte, 32)
+	copy(data, "MKR")
+	if got := decodeBytes32String(data); got != "MKR" {
+		t.Errorf("decodeBytes32String returned %q, expected %q.", got, "MKR")
+	}
+	if got := decodeBytes32String([]byte("MKR")); got != "" {
+		t.Errorf("decodeBytes32String returned %q for invalid input.", got)
+	}
+}
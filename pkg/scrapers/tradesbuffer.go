@@ -0,0 +1,172 @@
+package scrapers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+	"github.com/diadata-org/decentral-feeder/pkg/utils"
+)
+
+// tradesBuffer keeps the recent trades of each exchangepair, such that a tradesblock can span a lookback
+// window that is longer than the trigger period.
+// The lookback of an asset is LOOKBACK_SECONDS_<SYMBOL> and defaults to LOOKBACK_SECONDS. A lookback of 0
+// means that a tradesblock consists of the trades since the previous trigger.
+// At most TRADES_BUFFER_SIZE trades are kept per exchangepair. Once the buffer of an exchangepair is full,
+// its oldest trade is dropped for each new trade.
+type tradesBuffer struct {
+	// markets maps an exchangepair identifier onto the buffered trades of the exchangepair.
+	markets         map[string]*bufferedMarket
+	capacity        int
+	defaultLookback time.Duration
+	lookbacks       map[string]time.Duration
+	lastTrigger     time.Time
+}
+
+func newTradesBuffer() *tradesBuffer {
+	capacity := getenvInt("TRADES_BUFFER_SIZE", 10000)
+	if capacity == 0 {
+		log.Error("TRADES_BUFFER_SIZE must be positive.")
+		capacity = 10000
+	}
+	return &tradesBuffer{
+		markets:         make(map[string]*bufferedMarket),
+		capacity:        capacity,
+		defaultLookback: getenvSeconds("LOOKBACK_SECONDS", 0),
+		lookbacks:       make(map[string]time.Duration),
+	}
+}
+
+type bufferedMarket struct {
+	pair   models.Pair
+	trades tradesRing
+}
+
+// tradesRing is a ring buffer of trades in order of arrival.
+type tradesRing struct {
+	trades   []models.Trade
+	start    int
+	capacity int
+}
+
+// push appends @trade and returns true if the oldest trade had to be dropped for it.
+func (ring *tradesRing) push(trade models.Trade) bool {
+	if len(ring.trades) < ring.capacity {
+		ring.trades = append(ring.trades, trade)
+		return false
+	}
+	ring.trades[ring.start] = trade
+	ring.start = (ring.start + 1) % ring.capacity
+	return true
+}
+
+// all returns the buffered trades from oldest to newest.
+func (ring *tradesRing) all() []models.Trade {
+	return append(ring.trades[ring.start:len(ring.trades):len(ring.trades)], ring.trades[:ring.start]...)
+}
+
+// reset replaces the buffered trades by @trades, which must not exceed the capacity.
+func (ring *tradesRing) reset(trades []models.Trade) {
+	ring.trades = trades
+	ring.start = 0
+}
+
+// getenvInt returns the non-negative integer given by the environment variable @key.
+func getenvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(utils.Getenv(key, strconv.Itoa(defaultValue)))
+	if err != nil || value < 0 {
+		log.Errorf("parse %s: %v.", key, err)
+		return defaultValue
+	}
+	return value
+}
+
+// getenvSeconds returns the duration given in seconds by the environment variable @key.
+func getenvSeconds(key string, defaultSeconds int) time.Duration {
+	return time.Duration(getenvInt(key, defaultSeconds)) * time.Second
+}
+
+// lookback returns the lookback window of the asset with @symbol.
+func (buffer *tradesBuffer) lookback(symbol string) time.Duration {
+	if lookback, ok := buffer.lookbacks[symbol]; ok {
+		return lookback
+	}
+	lookback := buffer.defaultLookback
+	key := "LOOKBACK_SECONDS_" + strings.ToUpper(symbol)
+	if utils.Getenv(key, "") != "" {
+		lookback = getenvSeconds(key, int(buffer.defaultLookback/time.Second))
+	}
+	buffer.lookbacks[symbol] = lookback
+	return lookback
+}
+
+// add buffers @trade of the exchangepair with @exchangepairIdentifier.
+func (buffer *tradesBuffer) add(exchangepairIdentifier string, pair models.Pair, trade models.Trade) {
+	market, ok := buffer.markets[exchangepairIdentifier]
+	if !ok {
+		market = &bufferedMarket{pair: pair, trades: tradesRing{capacity: buffer.capacity}}
+		buffer.markets[exchangepairIdentifier] = market
+	}
+	if market.trades.push(trade) {
+		collectorDroppedTrades.WithLabelValues(trade.Exchange.Name).Inc()
+	}
+}
+
+// makeTradesblocks returns a tradesblock for each exchangepair with trades in the lookback window ending at @endTime.
+// Trades that are older than the window are discarded. Trades after @endTime are kept for the next trigger.
+func (buffer *tradesBuffer) makeTradesblocks(endTime time.Time) map[string]models.TradesBlock {
+	tradesblockMap := make(map[string]models.TradesBlock)
+
+	for id, market := range buffer.markets {
+		lookback := buffer.lookback(market.pair.QuoteToken.Symbol)
+
+		var (
+			trades []models.Trade
+			kept   []models.Trade
+		)
+		for _, trade := range market.trades.all() {
+			if trade.Time.After(endTime) {
+				kept = append(kept, trade)
+				continue
+			}
+			if lookback == 0 {
+				// Without lookback, every trade goes into exactly one tradesblock, even if it arrived late.
+				trades = append(trades, trade)
+				continue
+			}
+			if trade.Time.After(endTime.Add(-lookback)) {
+				trades = append(trades, trade)
+				kept = append(kept, trade)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(buffer.markets, id)
+		} else {
+			market.trades.reset(kept)
+		}
+		if len(trades) == 0 {
+			continue
+		}
+
+		startTime := endTime.Add(-lookback)
+		if lookback == 0 {
+			startTime = buffer.lastTrigger
+			for _, trade := range trades {
+				if startTime.IsZero() || trade.Time.Before(startTime) {
+					startTime = trade.Time
+				}
+			}
+		}
+		tradesblockMap[id] = models.TradesBlock{
+			Pair:      market.pair,
+			Trades:    trades,
+			StartTime: startTime,
+			EndTime:   endTime,
+		}
+	}
+
+	buffer.lastTrigger = endTime
+	return tradesblockMap
+}
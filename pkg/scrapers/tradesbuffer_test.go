@@ -0,0 +1,127 @@
+package scrapers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	models "github.com/diadata-org/decentral-feeder/pkg/models"
+)
+
+func TestTradesBufferMakeTradesblocks(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	eth := models.Pair{QuoteToken: models.Asset{Symbol: "ETH"}}
+	dia := models.Pair{QuoteToken: models.Asset{Symbol: "DIA"}}
+
+	buffer := &tradesBuffer{
+		markets:   make(map[string]*bufferedMarket),
+		capacity:  10,
+		lookbacks: map[string]time.Duration{"DIA": 5 * time.Minute},
+	}
+	buffer.add("Binance:ETH-USDT", eth, models.Trade{Price: 1, Time: t0.Add(5 * time.Second)})
+	buffer.add("Binance:DIA-USDT", dia, models.Trade{Price: 2, Time: t0.Add(10 * time.Second)})
+	// A trade after the trigger belongs to the next tradesblock.
+	buffer.add("Binance:ETH-USDT", eth, models.Trade{Price: 3, Time: t0.Add(25 * time.Second)})
+
+	tradesblocks := buffer.makeTradesblocks(t0.Add(20 * time.Second))
+	if len(tradesblocks) != 2 {
+		t.Fatalf("got %v tradesblocks, want 2", len(tradesblocks))
+	}
+	if tb := tradesblocks["Binance:ETH-USDT"]; len(tb.Trades) != 1 || tb.Trades[0].Price != 1 || !tb.StartTime.Equal(t0.Add(5*time.Second)) {
+		t.Errorf("unexpected ETH tradesblock %v", tb)
+	}
+	if tb := tradesblocks["Binance:DIA-USDT"]; !tb.StartTime.Equal(t0.Add(20*time.Second - 5*time.Minute)) {
+		t.Errorf("got DIA start time %v, want %v", tb.StartTime, t0.Add(20*time.Second-5*time.Minute))
+	}
+
+	// Without a new trade, DIA stays in the feed for its lookback while ETH only contains the later trade.
+	tradesblocks = buffer.makeTradesblocks(t0.Add(40 * time.Second))
+	if tb := tradesblocks["Binance:ETH-USDT"]; len(tb.Trades) != 1 || tb.Trades[0].Price != 3 || !tb.StartTime.Equal(t0.Add(20*time.Second)) {
+		t.Errorf("unexpected ETH tradesblock %v", tb)
+	}
+	if tb := tradesblocks["Binance:DIA-USDT"]; len(tb.Trades) != 1 || tb.Trades[0].Price != 2 {
+		t.Errorf("unexpected DIA tradesblock %v", tb)
+	}
+
+	// Once the DIA trade leaves the lookback window, the pair is dropped.
+	tradesblocks = buffer.makeTradesblocks(t0.Add(10 * time.Minute))
+	if len(tradesblocks) != 0 || len(buffer.markets) != 0 {
+		t.Errorf("got %v tradesblocks and %v buffered pairs, want none", len(tradesblocks), len(buffer.markets))
+	}
+}
+
+func TestTradesRing(t *testing.T) {
+	ring := tradesRing{capacity: 3}
+	for i := 1; i <= 5; i++ {
+		dropped := ring.push(models.Trade{Price: float64(i)})
+		if dropped != (i > 3) {
+			t.Errorf("push %v: got dropped %v", i, dropped)
+		}
+	}
+	trades := ring.all()
+	if len(trades) != 3 || trades[0].Price != 3 || trades[2].Price != 5 {
+		t.Errorf("got %v, want the newest 3 trades in order", trades)
+	}
+}
+
+func TestSendTradeCancelled(t *testing.T) {
+	tradesChannel := make(chan models.Trade)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sendTrade(ctx, BINANCE_EXCHANGE, tradesChannel, models.Trade{})
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sendTrade blocked on a full channel after cancellation.")
+	}
+}
+
+// BenchmarkCollectorThroughput pushes trades of 100 markets through a buffered channel into the
+// tradesBuffer and triggers a tradesblock every 10k trades, i.e. once per second at 10k trades/second.
+func BenchmarkCollectorThroughput(b *testing.B) {
+	const markets = 100
+	t0 := time.Now()
+	buffer := &tradesBuffer{
+		markets:   make(map[string]*bufferedMarket),
+		capacity:  1000,
+		lookbacks: make(map[string]time.Duration),
+	}
+	ids := make([]string, markets)
+	pairs := make([]models.Pair, markets)
+	for i := range ids {
+		pairs[i] = models.Pair{QuoteToken: models.Asset{Symbol: "SYM" + strconv.Itoa(i)}, BaseToken: models.Asset{Symbol: "USDT"}}
+		ids[i] = pairs[i].ExchangePairIdentifier(BINANCE_EXCHANGE)
+	}
+	tradesChannel := make(chan models.Trade, 1000)
+	done := make(chan struct{})
+	go func() {
+		var n int
+		for trade := range tradesChannel {
+			i := n % markets
+			buffer.add(ids[i], pairs[i], trade)
+			n++
+			if n%10000 == 0 {
+				buffer.makeTradesblocks(trade.Time)
+			}
+		}
+		close(done)
+	}()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trade := models.Trade{
+			Exchange: models.Exchange{Name: BINANCE_EXCHANGE},
+			Price:    float64(n),
+			Time:     t0.Add(time.Duration(n) * 100 * time.Microsecond),
+		}
+		sendTrade(context.Background(), BINANCE_EXCHANGE, tradesChannel, trade)
+	}
+	close(tradesChannel)
+	<-done
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "trades/s")
+}
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func Getenv(key, fallback string) string {
@@ -11,3 +14,13 @@ func Getenv(key, fallback string) string {
 	}
 	return value
 }
+
+// GetenvFloat returns the non-negative number in the environment variable @key, or @fallback if it is unset or invalid.
+func GetenvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(Getenv(key, strconv.FormatFloat(fallback, 'f', -1, 64)), 64)
+	if err != nil || value < 0 {
+		log.Errorf("Parse %s: %v.", key, err)
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const diaAssetQuotationBaseString = "https://api.diadata.org/v1/assetQuotation/"
+
+var (
+	// fixedQuotations maps asset identifiers onto USD prices that replace DIA's API, for instance in replays.
+	fixedQuotations     map[string]float64
+	fixedQuotationsLock sync.RWMutex
+)
+
+// SetFixedAssetQuotations makes GetAssetQuotation return the prices in @quotations, which maps
+// asset identifiers <Blockchain>-<Address> onto USD prices, instead of querying DIA's API.
+// @quotations is copied, so the caller may modify it afterwards.
+func SetFixedAssetQuotations(quotations map[string]float64) {
+	var fixed map[string]float64
+	if quotations != nil {
+		fixed = make(map[string]float64, len(quotations))
+		for asset, price := range quotations {
+			fixed[asset] = price
+		}
+	}
+	fixedQuotationsLock.Lock()
+	fixedQuotations = fixed
+	fixedQuotationsLock.Unlock()
+}
+
+// GetAssetQuotation returns the USD price of the asset with @address on @blockchain as provided by DIA's API.
+func GetAssetQuotation(blockchain string, address string) (price float64, err error) {
+	fixedQuotationsLock.RLock()
+	fixed := fixedQuotations
+	fixedQuotationsLock.RUnlock()
+	if fixed != nil {
+		var ok bool
+		price, ok = fixed[blockchain+"-"+address]
+		if !ok {
+			err = fmt.Errorf("no fixed quotation for %s-%s", blockchain, address)
+		}
+		return
+	}
+	type assetQuotation struct {
+		Price  float64 `json:"Price"`
+		Volume float64 `json:"VolumeYesterdayUSD"`
+	}
+	var (
+		response []byte
+		aq       assetQuotation
+	)
+
+	response, _, err = GetRequest(diaAssetQuotationBaseString + blockchain + "/" + address)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(response, &aq)
+	if err != nil {
+		return
+	}
+	price = aq.Price
+	return
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFixedAssetQuotations(t *testing.T) {
+	defer SetFixedAssetQuotations(nil)
+	quotations := map[string]float64{"Ethereum-0x0000000000000000000000000000000000000000": 3500}
+	SetFixedAssetQuotations(quotations)
+	// Changes of the caller's map do not affect the fixed quotations.
+	quotations["Ethereum-0x0000000000000000000000000000000000000000"] = 0
+
+	// Quotations are read by the processor's goroutines while they may be replaced.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			price, err := GetAssetQuotation("Ethereum", "0x0000000000000000000000000000000000000000")
+			if err != nil || price != 3500 {
+				t.Errorf("got price %v and error %v, expected 3500.", price, err)
+			}
+		}()
+	}
+	SetFixedAssetQuotations(map[string]float64{"Ethereum-0x0000000000000000000000000000000000000000": 3500})
+	wg.Wait()
+
+	if _, err := GetAssetQuotation("Ethereum", "0x1"); err == nil {
+		t.Error("expected an error for an asset without fixed quotation.")
+	}
+}
@@ -14,3 +14,37 @@ func Median(samples []float64) (median float64) {
 	}
 	return
 }
+
+// WeightedMedian returns the weighted median of @samples with respect to @weights.
+// If the cumulated weight hits exactly half of the total weight, the mean of the two adjacent samples is returned,
+// such that for equal weights the result coincides with Median.
+func WeightedMedian(samples []float64, weights []float64) (median float64) {
+	if len(samples) == 0 || len(samples) != len(weights) {
+		return
+	}
+
+	indices := make([]int, len(samples))
+	var totalWeight float64
+	for i := range samples {
+		indices[i] = i
+		totalWeight += weights[i]
+	}
+	if totalWeight <= 0 {
+		return
+	}
+	sort.SliceStable(indices, func(i, j int) bool { return samples[indices[i]] < samples[indices[j]] })
+
+	var cumulatedWeight float64
+	for k, i := range indices {
+		cumulatedWeight += weights[i]
+		if cumulatedWeight == totalWeight/2 && k < len(indices)-1 {
+			median = (samples[i] + samples[indices[k+1]]) / 2
+			return
+		}
+		if cumulatedWeight > totalWeight/2 {
+			median = samples[i]
+			return
+		}
+	}
+	return
+}
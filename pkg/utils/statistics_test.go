@@ -38,3 +38,50 @@ func TestMedian(t *testing.T) {
 	}
 
 }
+
+func TestWeightedMedian(t *testing.T) {
+	cases := []struct {
+		samples []float64
+		weights []float64
+		median  float64
+	}{
+		{
+			[]float64{3.31, 2.33, 9.01, 3.24, 1.53, 1.14},
+			[]float64{1, 1, 1, 1, 1, 1},
+			2.785,
+		},
+		{
+			[]float64{100, 101, 50},
+			[]float64{1, 1, 0.01},
+			100,
+		},
+		{
+			[]float64{100, 101, 50, 99},
+			[]float64{1, 1, 5, 1},
+			50,
+		},
+		{
+			[]float64{1},
+			[]float64{0.2},
+			1.0,
+		},
+		{
+			[]float64{1, 2},
+			[]float64{0, 0},
+			0.0,
+		},
+		{
+			[]float64{},
+			[]float64{},
+			0.0,
+		},
+	}
+
+	for i, c := range cases {
+		median := WeightedMedian(c.samples, c.weights)
+		if math.Abs(float64(median-c.median)) > 1e-4 {
+			t.Errorf("Weighted median was incorrect, got: %f, expected: %f for set:%d", median, c.median, i)
+		}
+	}
+
+}